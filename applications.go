@@ -0,0 +1,540 @@
+package tenant
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// SignInAudienceB2C is the sign-in audience of apps that authenticate users with user flows or custom policies
+const SignInAudienceB2C = "AzureADandPersonalMicrosoftAccount"
+
+// Platforms an application can have redirect URIs for
+const (
+	PlatformWeb          = "web"
+	PlatformSPA          = "spa"
+	PlatformPublicClient = "publicClient"
+)
+
+// ImplicitGrantSettings enables the implicit flow for web applications, SPAs should use PKCE instead
+type ImplicitGrantSettings struct {
+	EnableIDTokenIssuance     bool `json:"enableIdTokenIssuance"`
+	EnableAccessTokenIssuance bool `json:"enableAccessTokenIssuance"`
+}
+
+// WebApplication contains the settings of the web platform
+type WebApplication struct {
+	RedirectURIs          []string               `json:"redirectUris"`
+	ImplicitGrantSettings *ImplicitGrantSettings `json:"implicitGrantSettings,omitempty"`
+}
+
+// RedirectURIs contains the redirect URIs of the SPA or public client platform
+type RedirectURIs struct {
+	RedirectURIs []string `json:"redirectUris"`
+}
+
+// PermissionScope is a delegated permission an API exposes, e.g. "read"
+type PermissionScope struct {
+	ID                      string `json:"id"`
+	Value                   string `json:"value"`
+	AdminConsentDisplayName string `json:"adminConsentDisplayName"`
+	AdminConsentDescription string `json:"adminConsentDescription"`
+	IsEnabled               bool   `json:"isEnabled"`
+	Type                    string `json:"type"`
+}
+
+// APIApplication contains the scopes an application exposes
+type APIApplication struct {
+	OAuth2PermissionScopes []PermissionScope `json:"oauth2PermissionScopes"`
+}
+
+// ResourceAccess is a permission of a resource an application requires, Type is "Scope" or "Role"
+type ResourceAccess struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// RequiredResourceAccess contains the permissions an application requires of one resource, e.g. Microsoft Graph
+type RequiredResourceAccess struct {
+	ResourceAppID  string           `json:"resourceAppId"`
+	ResourceAccess []ResourceAccess `json:"resourceAccess"`
+}
+
+// PasswordCredential is a client secret of an application, SecretText is only returned when it is created
+type PasswordCredential struct {
+	KeyID         string `json:"keyId,omitempty"`
+	DisplayName   string `json:"displayName,omitempty"`
+	Hint          string `json:"hint,omitempty"`
+	SecretText    string `json:"secretText,omitempty"`
+	StartDateTime string `json:"startDateTime,omitempty"`
+	EndDateTime   string `json:"endDateTime,omitempty"`
+}
+
+// Application is an app registration. ID is the object ID used in the API, AppID the client ID used in tokens.
+// Empty fields are left unchanged by UpdateApplication.
+type Application struct {
+	ID                     string                   `json:"id,omitempty"`
+	AppID                  string                   `json:"appId,omitempty"`
+	DisplayName            string                   `json:"displayName,omitempty"`
+	SignInAudience         string                   `json:"signInAudience,omitempty"`
+	IdentifierURIs         []string                 `json:"identifierUris,omitempty"`
+	IsFallbackPublicClient *bool                    `json:"isFallbackPublicClient,omitempty"`
+	Web                    *WebApplication          `json:"web,omitempty"`
+	SPA                    *RedirectURIs            `json:"spa,omitempty"`
+	PublicClient           *RedirectURIs            `json:"publicClient,omitempty"`
+	API                    *APIApplication          `json:"api,omitempty"`
+	RequiredResourceAccess []RequiredResourceAccess `json:"requiredResourceAccess,omitempty"`
+	PasswordCredentials    []PasswordCredential     `json:"passwordCredentials,omitempty"`
+}
+
+// AppRole is an application permission a service principal offers
+type AppRole struct {
+	ID    string `json:"id"`
+	Value string `json:"value"`
+}
+
+// ServicePrincipal is the instance of an application in the tenant, it is needed to sign in and to grant consent
+type ServicePrincipal struct {
+	ID                     string            `json:"id,omitempty"`
+	AppID                  string            `json:"appId"`
+	DisplayName            string            `json:"displayName,omitempty"`
+	AppRoles               []AppRole         `json:"appRoles,omitempty"`
+	OAuth2PermissionScopes []PermissionScope `json:"oauth2PermissionScopes,omitempty"`
+}
+
+// oauth2PermissionGrant is the delegated admin consent of a client for a resource
+type oauth2PermissionGrant struct {
+	ID          string `json:"id,omitempty"`
+	ClientID    string `json:"clientId"`
+	ConsentType string `json:"consentType"`
+	ResourceID  string `json:"resourceId"`
+	Scope       string `json:"scope"`
+}
+
+// ListApplications returns all app registrations of the tenant
+func (t Tenant) ListApplications() ([]Application, error) {
+	applications := []Application{}
+	err := t.listPages("/applications", "", func(response []byte) (string, error) {
+		page := struct {
+			Value     []Application `json:"value"`
+			ODataNext string        `json:"@odata.nextLink"`
+		}{}
+		if err := json.Unmarshal(response, &page); err != nil {
+			return "", fmt.Errorf("error unmarshaling JSON response: %s", err)
+		}
+		applications = append(applications, page.Value...)
+		return page.ODataNext, nil
+	})
+	return applications, err
+}
+
+// GetApplication returns the app registration with the given object ID
+func (t Tenant) GetApplication(objectID string) (Application, error) {
+	application := Application{}
+	err := t.getObject("/applications/"+url.PathEscape(objectID), "", objectID, &application)
+	return application, err
+}
+
+// GetApplicationByAppID returns the app registration with the given app (client) ID
+func (t Tenant) GetApplicationByAppID(appID string) (Application, error) {
+	page := struct {
+		Value []Application `json:"value"`
+	}{}
+
+	if err := t.getObject("/applications", "$filter="+url.QueryEscape("appId eq "+odataString(appID)), appID, &page); err != nil {
+		return Application{}, err
+	}
+
+	if len(page.Value) == 0 {
+		return Application{}, fmt.Errorf("application %s not found", appID)
+	}
+
+	return page.Value[0], nil
+}
+
+// CreateApplication creates an app registration, SignInAudience defaults to SignInAudienceB2C
+func (t Tenant) CreateApplication(application Application) (Application, error) {
+	created := Application{}
+
+	if application.DisplayName == "" {
+		return created, errors.New("display name is required")
+	}
+
+	if application.SignInAudience == "" {
+		application.SignInAudience = SignInAudienceB2C
+	}
+
+	err := t.postObject("/applications", application, &created)
+	return created, err
+}
+
+// UpdateApplication changes the non-empty properties of application on the app registration
+func (t Tenant) UpdateApplication(objectID string, application Application) error {
+	// the IDs can't be changed and secrets are managed with AddPassword
+	application.ID = ""
+	application.AppID = ""
+	application.PasswordCredentials = nil
+
+	return t.sendObject("/applications/"+url.PathEscape(objectID), "PATCH", "updating", objectID, application)
+}
+
+// DeleteApplication deletes the app registration, its service principal is deleted with it
+func (t Tenant) DeleteApplication(objectID string) error {
+	return t.sendObject("/applications/"+url.PathEscape(objectID), "DELETE", "deleting", objectID, nil)
+}
+
+// AddRedirectURI adds a redirect URI to one of the platforms of the app registration, e.g. PlatformSPA
+func (t Tenant) AddRedirectURI(objectID string, platform string, redirectURI string) error {
+	return t.updateRedirectURIs(objectID, platform, func(uris []string) []string {
+		for _, uri := range uris {
+			if uri == redirectURI {
+				return uris
+			}
+		}
+		return append(uris, redirectURI)
+	})
+}
+
+// RemoveRedirectURI removes a redirect URI from one of the platforms of the app registration
+func (t Tenant) RemoveRedirectURI(objectID string, platform string, redirectURI string) error {
+	return t.updateRedirectURIs(objectID, platform, func(uris []string) []string {
+		remaining := []string{}
+		for _, uri := range uris {
+			if uri != redirectURI {
+				remaining = append(remaining, uri)
+			}
+		}
+		return remaining
+	})
+}
+
+// updateRedirectURIs replaces the redirect URIs of platform with the result of update
+func (t Tenant) updateRedirectURIs(objectID string, platform string, update func([]string) []string) error {
+	application, err := t.GetApplication(objectID)
+	if err != nil {
+		return err
+	}
+
+	patch := Application{}
+
+	// the platforms are replaced as a whole, so the other settings of web have to be sent as well
+	switch platform {
+	case PlatformWeb:
+		patch.Web = &WebApplication{}
+		if application.Web != nil {
+			*patch.Web = *application.Web
+		}
+		patch.Web.RedirectURIs = update(patch.Web.RedirectURIs)
+	case PlatformSPA:
+		patch.SPA = &RedirectURIs{}
+		if application.SPA != nil {
+			patch.SPA.RedirectURIs = application.SPA.RedirectURIs
+		}
+		patch.SPA.RedirectURIs = update(patch.SPA.RedirectURIs)
+	case PlatformPublicClient:
+		patch.PublicClient = &RedirectURIs{}
+		if application.PublicClient != nil {
+			patch.PublicClient.RedirectURIs = application.PublicClient.RedirectURIs
+		}
+		patch.PublicClient.RedirectURIs = update(patch.PublicClient.RedirectURIs)
+	default:
+		return fmt.Errorf("unknown platform %q", platform)
+	}
+
+	return t.UpdateApplication(objectID, patch)
+}
+
+// SetImplicitGrant enables or disables the implicit flow for ID and access tokens of the web platform
+func (t Tenant) SetImplicitGrant(objectID string, idTokens bool, accessTokens bool) error {
+	application, err := t.GetApplication(objectID)
+	if err != nil {
+		return err
+	}
+
+	web := WebApplication{RedirectURIs: []string{}}
+	if application.Web != nil && application.Web.RedirectURIs != nil {
+		web.RedirectURIs = application.Web.RedirectURIs
+	}
+	web.ImplicitGrantSettings = &ImplicitGrantSettings{EnableIDTokenIssuance: idTokens, EnableAccessTokenIssuance: accessTokens}
+
+	return t.UpdateApplication(objectID, Application{Web: &web})
+}
+
+// ExposeScope adds a delegated permission to the API of the app registration, e.g. "read". If the app has no
+// identifier URI yet, identifierURI is set, e.g. https://<tenant>.onmicrosoft.com/<api>. A missing scope ID is generated.
+func (t Tenant) ExposeScope(objectID string, identifierURI string, scope PermissionScope) (PermissionScope, error) {
+	application, err := t.GetApplication(objectID)
+	if err != nil {
+		return scope, err
+	}
+
+	if scope.Value == "" {
+		return scope, errors.New("scope value is empty")
+	}
+
+	if scope.ID == "" {
+		if scope.ID, err = newUUID(); err != nil {
+			return scope, err
+		}
+	}
+
+	if scope.Type == "" {
+		scope.Type = "Admin"
+	}
+
+	scope.IsEnabled = true
+
+	api := APIApplication{OAuth2PermissionScopes: []PermissionScope{}}
+	if application.API != nil {
+		api.OAuth2PermissionScopes = application.API.OAuth2PermissionScopes
+	}
+
+	for _, existing := range api.OAuth2PermissionScopes {
+		if existing.Value == scope.Value {
+			return existing, fmt.Errorf("scope %s is already exposed", scope.Value)
+		}
+	}
+
+	api.OAuth2PermissionScopes = append(api.OAuth2PermissionScopes, scope)
+
+	patch := Application{API: &api}
+	if len(application.IdentifierURIs) == 0 {
+		if identifierURI == "" {
+			return scope, errors.New("the application has no identifier URI")
+		}
+		patch.IdentifierURIs = []string{identifierURI}
+	}
+
+	return scope, t.UpdateApplication(objectID, patch)
+}
+
+// AddRequiredPermissions adds permissions of a resource to the permissions the app registration requires,
+// e.g. the openid and offline_access scopes of Microsoft Graph or a scope exposed by another app.
+// Type is "Scope" for delegated permissions and "Role" for application permissions.
+func (t Tenant) AddRequiredPermissions(objectID string, resourceAppID string, permissions ...ResourceAccess) error {
+	application, err := t.GetApplication(objectID)
+	if err != nil {
+		return err
+	}
+
+	required := application.RequiredResourceAccess
+
+	index := -1
+	for i, rra := range required {
+		if rra.ResourceAppID == resourceAppID {
+			index = i
+		}
+	}
+
+	if index < 0 {
+		required = append(required, RequiredResourceAccess{ResourceAppID: resourceAppID, ResourceAccess: []ResourceAccess{}})
+		index = len(required) - 1
+	}
+
+	for _, permission := range permissions {
+		found := false
+		for _, existing := range required[index].ResourceAccess {
+			if existing.ID == permission.ID {
+				found = true
+			}
+		}
+		if !found {
+			required[index].ResourceAccess = append(required[index].ResourceAccess, permission)
+		}
+	}
+
+	return t.UpdateApplication(objectID, Application{RequiredResourceAccess: required})
+}
+
+// GetServicePrincipalByAppID returns the service principal of an application in the tenant
+func (t Tenant) GetServicePrincipalByAppID(appID string) (ServicePrincipal, error) {
+	servicePrincipal, err := t.findServicePrincipal(appID)
+	if err != nil {
+		return ServicePrincipal{}, err
+	}
+
+	if servicePrincipal == nil {
+		return ServicePrincipal{}, fmt.Errorf("service principal of %s not found", appID)
+	}
+
+	return *servicePrincipal, nil
+}
+
+// findServicePrincipal returns the service principal of an application, or nil if there is none
+func (t Tenant) findServicePrincipal(appID string) (*ServicePrincipal, error) {
+	page := struct {
+		Value []ServicePrincipal `json:"value"`
+	}{}
+
+	if err := t.getObject("/servicePrincipals", "$filter="+url.QueryEscape("appId eq "+odataString(appID)), appID, &page); err != nil {
+		return nil, err
+	}
+
+	if len(page.Value) == 0 {
+		return nil, nil
+	}
+
+	return &page.Value[0], nil
+}
+
+// CreateServicePrincipal creates the service principal of an app registration
+func (t Tenant) CreateServicePrincipal(appID string) (ServicePrincipal, error) {
+	created := ServicePrincipal{}
+
+	if appID == "" {
+		return created, errors.New("app ID is empty")
+	}
+
+	err := t.postObject("/servicePrincipals", ServicePrincipal{AppID: appID}, &created)
+	return created, err
+}
+
+// DeleteServicePrincipal deletes a service principal by its object ID
+func (t Tenant) DeleteServicePrincipal(objectID string) error {
+	return t.sendObject("/servicePrincipals/"+url.PathEscape(objectID), "DELETE", "deleting", objectID, nil)
+}
+
+// GrantAdminConsent grants the permissions the app registration requires for all users, like the
+// "Grant admin consent" button in the portal. The service principal of the app is created if it doesn't exist.
+func (t Tenant) GrantAdminConsent(appID string) error {
+	application, err := t.GetApplicationByAppID(appID)
+	if err != nil {
+		return err
+	}
+
+	found, err := t.findServicePrincipal(appID)
+	if err != nil {
+		return err
+	}
+
+	client := ServicePrincipal{}
+	if found != nil {
+		client = *found
+	} else if client, err = t.CreateServicePrincipal(appID); err != nil {
+		return err
+	}
+
+	for _, rra := range application.RequiredResourceAccess {
+		resource, err := t.GetServicePrincipalByAppID(rra.ResourceAppID)
+		if err != nil {
+			return err
+		}
+
+		scopes := []string{}
+
+		for _, access := range rra.ResourceAccess {
+			switch access.Type {
+			case "Scope":
+				value, err := scopeValue(resource, access.ID)
+				if err != nil {
+					return err
+				}
+				scopes = append(scopes, value)
+			case "Role":
+				if err := t.assignAppRole(client, resource, access.ID); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(scopes) > 0 {
+			if err := t.grantScopes(client, resource, scopes); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// grantScopes creates or extends the delegated permission grant of client for resource
+func (t Tenant) grantScopes(client ServicePrincipal, resource ServicePrincipal, scopes []string) error {
+	page := struct {
+		Value []oauth2PermissionGrant `json:"value"`
+	}{}
+
+	filter := "clientId eq " + odataString(client.ID) + " and resourceId eq " + odataString(resource.ID) + " and consentType eq 'AllPrincipals'"
+	if err := t.getObject("/oauth2PermissionGrants", "$filter="+url.QueryEscape(filter), client.ID, &page); err != nil {
+		return err
+	}
+
+	if len(page.Value) == 0 {
+		grant := oauth2PermissionGrant{ClientID: client.ID, ConsentType: "AllPrincipals", ResourceID: resource.ID, Scope: strings.Join(scopes, " ")}
+		return t.postObject("/oauth2PermissionGrants", grant, &grant)
+	}
+
+	grant := page.Value[0]
+	granted := strings.Fields(grant.Scope)
+
+	for _, scope := range scopes {
+		found := false
+		for _, existing := range granted {
+			if existing == scope {
+				found = true
+			}
+		}
+		if !found {
+			granted = append(granted, scope)
+		}
+	}
+
+	return t.sendObject("/oauth2PermissionGrants/"+url.PathEscape(grant.ID), "PATCH", "updating", grant.ID, map[string]string{"scope": strings.Join(granted, " ")})
+}
+
+// assignAppRole grants an application permission of resource to client, existing assignments are kept
+func (t Tenant) assignAppRole(client ServicePrincipal, resource ServicePrincipal, appRoleID string) error {
+	assigned := false
+
+	err := t.listPages("/servicePrincipals/"+url.PathEscape(client.ID)+"/appRoleAssignments", "", func(response []byte) (string, error) {
+		page := struct {
+			Value []struct {
+				AppRoleID  string `json:"appRoleId"`
+				ResourceID string `json:"resourceId"`
+			} `json:"value"`
+			ODataNext string `json:"@odata.nextLink"`
+		}{}
+		if err := json.Unmarshal(response, &page); err != nil {
+			return "", fmt.Errorf("error unmarshaling JSON response: %s", err)
+		}
+		for _, assignment := range page.Value {
+			if assignment.AppRoleID == appRoleID && assignment.ResourceID == resource.ID {
+				assigned = true
+				return "", nil
+			}
+		}
+		return page.ODataNext, nil
+	})
+	if err != nil || assigned {
+		return err
+	}
+
+	assignment := map[string]string{"principalId": client.ID, "resourceId": resource.ID, "appRoleId": appRoleID}
+	created := map[string]interface{}{}
+	return t.postObject("/servicePrincipals/"+url.PathEscape(resource.ID)+"/appRoleAssignedTo", assignment, &created)
+}
+
+// scopeValue returns the name of a delegated permission of resource, e.g. "openid"
+func scopeValue(resource ServicePrincipal, scopeID string) (string, error) {
+	for _, scope := range resource.OAuth2PermissionScopes {
+		if scope.ID == scopeID {
+			return scope.Value, nil
+		}
+	}
+	return "", fmt.Errorf("%s has no scope %s", resource.DisplayName, scopeID)
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating UUID: %s", err)
+	}
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,239 @@
+package tenant
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAddRedirectURI(t *testing.T) {
+	var patch map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PATCH" {
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &patch)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(`{"id": "obj1", "appId": "app1", "web": {"redirectUris": ["https://example.com/a"], "implicitGrantSettings": {"enableIdTokenIssuance": true}}}`))
+	}))
+	defer server.Close()
+
+	tn := Tenant{Cloud: Cloud{LoginURL: server.URL + "/", GraphURL: server.URL + "/"}}
+
+	if err := tn.AddRedirectURI("obj1", PlatformWeb, "https://example.com/b"); err != nil {
+		t.Fatalf("Error while adding redirect URI: %s", err)
+	}
+
+	web, _ := patch["web"].(map[string]interface{})
+	uris, _ := web["redirectUris"].([]interface{})
+	implicit, _ := web["implicitGrantSettings"].(map[string]interface{})
+
+	if len(patch) != 1 || len(uris) != 2 || uris[1] != "https://example.com/b" || implicit["enableIdTokenIssuance"] != true {
+		t.Errorf("Unexpected update %v", patch)
+	}
+
+	if err := tn.AddRedirectURI("obj1", "desktop", "https://example.com/b"); err == nil {
+		t.Errorf("Expected error for unknown platform")
+	}
+}
+
+func TestSetImplicitGrant(t *testing.T) {
+	var patch map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PATCH" {
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &patch)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(`{"id": "obj1", "appId": "app1", "web": {"redirectUris": ["https://example.com/a"]}}`))
+	}))
+	defer server.Close()
+
+	tn := Tenant{Cloud: Cloud{LoginURL: server.URL + "/", GraphURL: server.URL + "/"}}
+
+	if err := tn.SetImplicitGrant("obj1", true, false); err != nil {
+		t.Fatalf("Error while setting implicit grant: %s", err)
+	}
+
+	web, _ := patch["web"].(map[string]interface{})
+	uris, _ := web["redirectUris"].([]interface{})
+	implicit, _ := web["implicitGrantSettings"].(map[string]interface{})
+
+	if len(uris) != 1 || implicit["enableIdTokenIssuance"] != true || implicit["enableAccessTokenIssuance"] != false {
+		t.Errorf("Unexpected update %v", patch)
+	}
+}
+
+func TestExposeScope(t *testing.T) {
+	var patch map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PATCH" {
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &patch)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(`{"id": "obj1", "appId": "app1", "api": {"oauth2PermissionScopes": [{"id": "s1", "value": "read", "isEnabled": true}]}}`))
+	}))
+	defer server.Close()
+
+	tn := Tenant{Cloud: Cloud{LoginURL: server.URL + "/", GraphURL: server.URL + "/"}}
+
+	if _, err := tn.ExposeScope("obj1", "https://example.onmicrosoft.com/api", PermissionScope{Value: "read"}); err == nil || patch != nil {
+		t.Errorf("Expected error for an existing scope, sent %v", patch)
+	}
+
+	scope, err := tn.ExposeScope("obj1", "https://example.onmicrosoft.com/api", PermissionScope{Value: "write"})
+	if err != nil {
+		t.Fatalf("Error while exposing scope: %s", err)
+	}
+
+	api, _ := patch["api"].(map[string]interface{})
+	scopes, _ := api["oauth2PermissionScopes"].([]interface{})
+	uris, _ := patch["identifierUris"].([]interface{})
+
+	if scope.ID == "" || scope.Type != "Admin" || !scope.IsEnabled || len(scopes) != 2 || len(uris) != 1 || uris[0] != "https://example.onmicrosoft.com/api" {
+		t.Errorf("Unexpected scope %+v exposed with %v", scope, patch)
+	}
+}
+
+func TestAddRequiredPermissions(t *testing.T) {
+	var patch Application
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PATCH" {
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &patch)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(`{"id": "obj1", "appId": "app1", "requiredResourceAccess": [{"resourceAppId": "graph", "resourceAccess": [{"id": "openid", "type": "Scope"}]}]}`))
+	}))
+	defer server.Close()
+
+	tn := Tenant{Cloud: Cloud{LoginURL: server.URL + "/", GraphURL: server.URL + "/"}}
+
+	err := tn.AddRequiredPermissions("obj1", "graph", ResourceAccess{ID: "openid", Type: "Scope"}, ResourceAccess{ID: "offline_access", Type: "Scope"})
+	if err != nil {
+		t.Fatalf("Error while adding permissions: %s", err)
+	}
+
+	// openid is already required and not added twice
+	if len(patch.RequiredResourceAccess) != 1 || len(patch.RequiredResourceAccess[0].ResourceAccess) != 2 || patch.RequiredResourceAccess[0].ResourceAccess[1].ID != "offline_access" {
+		t.Errorf("Unexpected update %+v", patch)
+	}
+
+	patch = Application{}
+	if err := tn.AddRequiredPermissions("obj1", "api", ResourceAccess{ID: "read", Type: "Scope"}); err != nil {
+		t.Fatalf("Error while adding permissions: %s", err)
+	}
+
+	if len(patch.RequiredResourceAccess) != 2 || len(patch.RequiredResourceAccess[0].ResourceAccess) != 1 || patch.RequiredResourceAccess[1].ResourceAppID != "api" {
+		t.Errorf("Unexpected update %+v", patch)
+	}
+}
+
+func TestGrantAdminConsent(t *testing.T) {
+	grantedScope := ""
+	grantsCreated := 0
+	assignedRoles := []string{}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter := r.URL.Query().Get("$filter")
+
+		switch r.Method + " " + r.URL.Path {
+		case "GET /beta/applications":
+			w.Write([]byte(`{"value": [{"id": "obj1", "appId": "client-app", "requiredResourceAccess": [{"resourceAppId": "graph-app", "resourceAccess": [
+				{"id": "openid-id", "type": "Scope"}, {"id": "offline-id", "type": "Scope"}, {"id": "role-1", "type": "Role"}, {"id": "role-2", "type": "Role"}
+			]}]}]}`))
+		case "GET /beta/servicePrincipals":
+			if strings.Contains(filter, "'client-app'") {
+				w.Write([]byte(`{"value": [{"id": "client-sp", "appId": "client-app"}]}`))
+				return
+			}
+			w.Write([]byte(`{"value": [{"id": "graph-sp", "appId": "graph-app", "oauth2PermissionScopes": [{"id": "openid-id", "value": "openid"}, {"id": "offline-id", "value": "offline_access"}]}]}`))
+		case "GET /beta/oauth2PermissionGrants":
+			w.Write([]byte(`{"value": [{"id": "grant1", "clientId": "client-sp", "consentType": "AllPrincipals", "resourceId": "graph-sp", "scope": "offline_access"}]}`))
+		case "POST /beta/oauth2PermissionGrants":
+			grantsCreated++
+			w.Write([]byte(`{}`))
+		case "PATCH /beta/oauth2PermissionGrants/grant1":
+			body, _ := ioutil.ReadAll(r.Body)
+			payload := map[string]string{}
+			json.Unmarshal(body, &payload)
+			grantedScope = payload["scope"]
+			w.WriteHeader(http.StatusNoContent)
+		case "GET /beta/servicePrincipals/client-sp/appRoleAssignments":
+			// role-2 is already assigned, but only on the second page
+			if r.URL.Query().Get("$skiptoken") == "" {
+				w.Write([]byte(`{"value": [{"appRoleId": "other", "resourceId": "graph-sp"}], "@odata.nextLink": "` + server.URL + `/beta/servicePrincipals/client-sp/appRoleAssignments?$skiptoken=2"}`))
+				return
+			}
+			w.Write([]byte(`{"value": [{"appRoleId": "role-2", "resourceId": "graph-sp"}]}`))
+		case "POST /beta/servicePrincipals/graph-sp/appRoleAssignedTo":
+			body, _ := ioutil.ReadAll(r.Body)
+			payload := map[string]string{}
+			json.Unmarshal(body, &payload)
+			assignedRoles = append(assignedRoles, payload["appRoleId"])
+			w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tn := Tenant{Cloud: Cloud{LoginURL: server.URL + "/", GraphURL: server.URL + "/"}}
+
+	if err := tn.GrantAdminConsent("client-app"); err != nil {
+		t.Fatalf("Error while granting admin consent: %s", err)
+	}
+
+	if grantsCreated != 0 || grantedScope != "offline_access openid" {
+		t.Errorf("Expected the existing grant to be extended, created %d grants, granted %q", grantsCreated, grantedScope)
+	}
+
+	if len(assignedRoles) != 1 || assignedRoles[0] != "role-1" {
+		t.Errorf("Expected only role-1 to be assigned, got %v", assignedRoles)
+	}
+}
+
+func TestNewUUID(t *testing.T) {
+	id, err := newUUID()
+	if err != nil {
+		t.Fatalf("Error generating UUID: %s", err)
+	}
+
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id) {
+		t.Errorf("Invalid UUID %s", id)
+	}
+}
+
+func TestListApplications(t *testing.T) {
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
+
+	if err := tn.GetGraphAccessToken(); err != nil {
+		t.Errorf("Error while obtaining access token: %s", err)
+	}
+
+	applications, err := tn.ListApplications()
+	if err != nil {
+		t.Fatalf("Error while listing applications: %s", err)
+	}
+
+	if len(applications) == 0 {
+		t.Errorf("Expected at least the b2c-extensions-app")
+	}
+}
@@ -0,0 +1,117 @@
+package tenant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// extensionsAppName is the display name prefix of the app B2C stores custom attributes on
+const extensionsAppName = "b2c-extensions-app"
+
+// Data types of custom user attributes
+const (
+	AttributeString  = "string"
+	AttributeInt     = "int64"
+	AttributeBoolean = "boolean"
+)
+
+// UserFlowAttribute is a built-in or custom user attribute that can be collected by user flows.
+// The ID of custom attributes is the property name on users, extension_<appId>_<name>.
+type UserFlowAttribute struct {
+	ID                    string `json:"id,omitempty"`
+	DisplayName           string `json:"displayName"`
+	Description           string `json:"description"`
+	DataType              string `json:"dataType"`
+	UserFlowAttributeType string `json:"userFlowAttributeType,omitempty"`
+}
+
+// IsCustom returns true for attributes defined in the tenant
+func (a UserFlowAttribute) IsCustom() bool {
+	return a.UserFlowAttributeType == "custom"
+}
+
+// ListUserFlowAttributes returns the built-in and custom user attributes of the tenant
+func (t Tenant) ListUserFlowAttributes() ([]UserFlowAttribute, error) {
+	attributes := []UserFlowAttribute{}
+	err := t.listPages("/identity/userFlowAttributes", "", func(response []byte) (string, error) {
+		page := struct {
+			Value     []UserFlowAttribute `json:"value"`
+			ODataNext string              `json:"@odata.nextLink"`
+		}{}
+		if err := json.Unmarshal(response, &page); err != nil {
+			return "", fmt.Errorf("error unmarshaling JSON response: %s", err)
+		}
+		attributes = append(attributes, page.Value...)
+		return page.ODataNext, nil
+	})
+	return attributes, err
+}
+
+// CreateUserFlowAttribute defines a custom user attribute, the returned attribute contains its ID.
+// The data type can't be changed later.
+func (t Tenant) CreateUserFlowAttribute(name string, description string, dataType string) (UserFlowAttribute, error) {
+	created := UserFlowAttribute{}
+
+	switch dataType {
+	case AttributeString, AttributeInt, AttributeBoolean:
+	default:
+		return created, fmt.Errorf("unsupported data type %q", dataType)
+	}
+
+	if name == "" {
+		return created, errors.New("attribute name is empty")
+	}
+
+	err := t.postObject("/identity/userFlowAttributes", UserFlowAttribute{DisplayName: name, Description: description, DataType: dataType}, &created)
+	return created, err
+}
+
+// DeleteUserFlowAttribute deletes a custom user attribute by its ID. The values stored on users are not removed.
+func (t Tenant) DeleteUserFlowAttribute(attributeID string) error {
+	return t.sendObject("/identity/userFlowAttributes/"+url.PathEscape(attributeID), "DELETE", "deleting", attributeID, nil)
+}
+
+// GetExtensionsAppID returns the app ID of the b2c-extensions-app, which custom attributes are stored on
+func (t Tenant) GetExtensionsAppID() (string, error) {
+	param := "$select=appId,displayName&$filter=" + url.QueryEscape("startswith(displayName,'"+extensionsAppName+"')")
+
+	page := struct {
+		Value []struct {
+			AppID       string `json:"appId"`
+			DisplayName string `json:"displayName"`
+		} `json:"value"`
+	}{}
+
+	if err := t.getObject("/applications", param, extensionsAppName, &page); err != nil {
+		return "", err
+	}
+
+	if len(page.Value) == 0 {
+		return "", fmt.Errorf("%s not found", extensionsAppName)
+	}
+
+	return page.Value[0].AppID, nil
+}
+
+// ExtensionAttributeName returns the property name of a custom attribute on users, e.g.
+// extension_1c2d3e4f..._ShoeSize for the attribute ShoeSize
+func ExtensionAttributeName(extensionsAppID string, attributeName string) string {
+	return "extension_" + strings.Replace(extensionsAppID, "-", "", -1) + "_" + attributeName
+}
+
+// AttributeNameFromExtension returns the attribute name of a user property name, ok is false if it is no custom attribute
+func AttributeNameFromExtension(propertyName string) (name string, ok bool) {
+	if !strings.HasPrefix(propertyName, "extension_") {
+		return "", false
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(propertyName, "extension_"), "_", 2)
+	if len(parts) != 2 || len(parts[0]) != 32 || parts[1] == "" {
+		return "", false
+	}
+
+	return parts[1], true
+}
@@ -0,0 +1,49 @@
+package tenant
+
+import (
+	"testing"
+)
+
+func TestExtensionAttributeName(t *testing.T) {
+	name := ExtensionAttributeName("1c2d3e4f-0000-1111-2222-333344445555", "ShoeSize")
+	if name != "extension_1c2d3e4f000011112222333344445555_ShoeSize" {
+		t.Errorf("Unexpected property name %s", name)
+	}
+
+	if attribute, ok := AttributeNameFromExtension(name); !ok || attribute != "ShoeSize" {
+		t.Errorf("Unexpected attribute name %q from %s", attribute, name)
+	}
+
+	for _, invalid := range []string{"city", "extension_abc_ShoeSize", "extension_1c2d3e4f000011112222333344445555_"} {
+		if _, ok := AttributeNameFromExtension(invalid); ok {
+			t.Errorf("Expected %s to be no custom attribute", invalid)
+		}
+	}
+}
+
+func TestListUserFlowAttributes(t *testing.T) {
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
+
+	if err := tn.GetGraphAccessToken(); err != nil {
+		t.Errorf("Error while obtaining access token: %s", err)
+	}
+
+	appID, err := tn.GetExtensionsAppID()
+	if err != nil {
+		t.Fatalf("Error while reading extensions app: %s", err)
+	}
+
+	attributes, err := tn.ListUserFlowAttributes()
+	if err != nil {
+		t.Fatalf("Error while listing attributes: %s", err)
+	}
+
+	for _, attribute := range attributes {
+		if attribute.IsCustom() && attribute.ID != ExtensionAttributeName(appID, attribute.DisplayName) {
+			t.Errorf("Unexpected ID %s of custom attribute %s", attribute.ID, attribute.DisplayName)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package tenant
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// auditLogPageSize is the page size requested from the audit log endpoints
+const auditLogPageSize = 500
+
+// AuditUser is the user that initiated an audited activity
+type AuditUser struct {
+	ID                string `json:"id"`
+	DisplayName       string `json:"displayName"`
+	UserPrincipalName string `json:"userPrincipalName"`
+	IPAddress         string `json:"ipAddress"`
+}
+
+// AuditApp is the application that initiated an audited activity
+type AuditApp struct {
+	AppID                string `json:"appId"`
+	DisplayName          string `json:"displayName"`
+	ServicePrincipalID   string `json:"servicePrincipalId"`
+	ServicePrincipalName string `json:"servicePrincipalName"`
+}
+
+// AuditInitiatedBy contains either the user or the app that initiated an audited activity
+type AuditInitiatedBy struct {
+	User *AuditUser `json:"user,omitempty"`
+	App  *AuditApp  `json:"app,omitempty"`
+}
+
+// ModifiedProperty is a property of a target resource that was changed by an audited activity
+type ModifiedProperty struct {
+	DisplayName string `json:"displayName"`
+	OldValue    string `json:"oldValue"`
+	NewValue    string `json:"newValue"`
+}
+
+// TargetResource is an object that was changed by an audited activity, e.g. a user or group
+type TargetResource struct {
+	ID                 string             `json:"id"`
+	DisplayName        string             `json:"displayName"`
+	Type               string             `json:"type"`
+	UserPrincipalName  string             `json:"userPrincipalName"`
+	ModifiedProperties []ModifiedProperty `json:"modifiedProperties"`
+}
+
+// KeyValue is an additional detail of an audited activity
+type KeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// AuditEvent is an entry of the directory audit log
+type AuditEvent struct {
+	ID                  string           `json:"id"`
+	Category            string           `json:"category"`
+	CorrelationID       string           `json:"correlationId"`
+	Result              string           `json:"result"`
+	ResultReason        string           `json:"resultReason"`
+	ActivityDisplayName string           `json:"activityDisplayName"`
+	ActivityDateTime    time.Time        `json:"activityDateTime"`
+	LoggedByService     string           `json:"loggedByService"`
+	OperationType       string           `json:"operationType"`
+	InitiatedBy         AuditInitiatedBy `json:"initiatedBy"`
+	TargetResources     []TargetResource `json:"targetResources"`
+	AdditionalDetails   []KeyValue       `json:"additionalDetails"`
+}
+
+// SignInStatus is the result of a sign-in, ErrorCode 0 is a successful sign-in
+type SignInStatus struct {
+	ErrorCode         int    `json:"errorCode"`
+	FailureReason     string `json:"failureReason"`
+	AdditionalDetails string `json:"additionalDetails"`
+}
+
+// SignInLocation is the location a sign-in came from
+type SignInLocation struct {
+	City            string `json:"city"`
+	State           string `json:"state"`
+	CountryOrRegion string `json:"countryOrRegion"`
+}
+
+// SignInEvent is an entry of the sign-in log
+type SignInEvent struct {
+	ID                      string         `json:"id"`
+	CreatedDateTime         time.Time      `json:"createdDateTime"`
+	UserID                  string         `json:"userId"`
+	UserDisplayName         string         `json:"userDisplayName"`
+	UserPrincipalName       string         `json:"userPrincipalName"`
+	AppID                   string         `json:"appId"`
+	AppDisplayName          string         `json:"appDisplayName"`
+	IPAddress               string         `json:"ipAddress"`
+	ClientAppUsed           string         `json:"clientAppUsed"`
+	CorrelationID           string         `json:"correlationId"`
+	ConditionalAccessStatus string         `json:"conditionalAccessStatus"`
+	IsInteractive           bool           `json:"isInteractive"`
+	Status                  SignInStatus   `json:"status"`
+	Location                SignInLocation `json:"location"`
+}
+
+// AuditFilter restricts the directory audit log query, empty fields are ignored
+type AuditFilter struct {
+	From time.Time
+	To   time.Time
+	// Activity is the activity display name, e.g. "Update user"
+	Activity string
+	// TargetID is the object ID of a target resource, e.g. a user or group
+	TargetID string
+	// InitiatedByUserID and InitiatedByAppID are the object ID of the user or the app ID that initiated the activity
+	InitiatedByUserID string
+	InitiatedByAppID  string
+	// Result is "success" or "failure"
+	Result string
+}
+
+// SignInFilter restricts the sign-in log query, empty fields are ignored
+type SignInFilter struct {
+	From   time.Time
+	To     time.Time
+	UserID string
+	AppID  string
+	// Result is "success" or "failure"
+	Result string
+}
+
+// auditEventsResponse is a page of the directory audit log
+type auditEventsResponse struct {
+	Value     []AuditEvent `json:"value"`
+	ODataNext string       `json:"@odata.nextLink"`
+}
+
+// signInEventsResponse is a page of the sign-in log
+type signInEventsResponse struct {
+	Value     []SignInEvent `json:"value"`
+	ODataNext string        `json:"@odata.nextLink"`
+}
+
+// ListDirectoryAudits calls fn for each entry of the directory audit log matching filter, newest first.
+// Returning an error from fn stops the query and returns the error.
+func (t Tenant) ListDirectoryAudits(filter AuditFilter, fn func(AuditEvent) error) error {
+	return t.listPages("/auditLogs/directoryAudits", filter.query(), func(response []byte) (string, error) {
+		aer := auditEventsResponse{}
+		if err := json.Unmarshal(response, &aer); err != nil {
+			return "", fmt.Errorf("error unmarshaling JSON response: %s", err)
+		}
+
+		for _, event := range aer.Value {
+			if err := fn(event); err != nil {
+				return "", err
+			}
+		}
+		return aer.ODataNext, nil
+	})
+}
+
+// GetDirectoryAudits returns all entries of the directory audit log matching filter
+func (t Tenant) GetDirectoryAudits(filter AuditFilter) ([]AuditEvent, error) {
+	events := []AuditEvent{}
+	err := t.ListDirectoryAudits(filter, func(event AuditEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	return events, err
+}
+
+// ListSignIns calls fn for each entry of the sign-in log matching filter, newest first.
+// Returning an error from fn stops the query and returns the error.
+func (t Tenant) ListSignIns(filter SignInFilter, fn func(SignInEvent) error) error {
+	return t.listPages("/auditLogs/signIns", filter.query(), func(response []byte) (string, error) {
+		ser := signInEventsResponse{}
+		if err := json.Unmarshal(response, &ser); err != nil {
+			return "", fmt.Errorf("error unmarshaling JSON response: %s", err)
+		}
+
+		for _, event := range ser.Value {
+			if err := fn(event); err != nil {
+				return "", err
+			}
+		}
+		return ser.ODataNext, nil
+	})
+}
+
+// GetSignIns returns all entries of the sign-in log matching filter
+func (t Tenant) GetSignIns(filter SignInFilter) ([]SignInEvent, error) {
+	events := []SignInEvent{}
+	err := t.ListSignIns(filter, func(event SignInEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	return events, err
+}
+
+// WriteDirectoryAudits writes the directory audit log entries matching filter to w as JSON lines
+func (t Tenant) WriteDirectoryAudits(w io.Writer, filter AuditFilter) error {
+	encoder := json.NewEncoder(w)
+	return t.ListDirectoryAudits(filter, func(event AuditEvent) error {
+		return encoder.Encode(event)
+	})
+}
+
+// WriteSignIns writes the sign-in log entries matching filter to w as JSON lines
+func (t Tenant) WriteSignIns(w io.Writer, filter SignInFilter) error {
+	encoder := json.NewEncoder(w)
+	return t.ListSignIns(filter, func(event SignInEvent) error {
+		return encoder.Encode(event)
+	})
+}
+
+// query returns the query parameters for the directory audit log
+func (f AuditFilter) query() string {
+	clauses := timeRangeClauses("activityDateTime", f.From, f.To)
+
+	if f.Activity != "" {
+		clauses = append(clauses, "activityDisplayName eq "+odataString(f.Activity))
+	}
+	if f.TargetID != "" {
+		clauses = append(clauses, "targetResources/any(t:t/id eq "+odataString(f.TargetID)+")")
+	}
+	if f.InitiatedByUserID != "" {
+		clauses = append(clauses, "initiatedBy/user/id eq "+odataString(f.InitiatedByUserID))
+	}
+	if f.InitiatedByAppID != "" {
+		clauses = append(clauses, "initiatedBy/app/appId eq "+odataString(f.InitiatedByAppID))
+	}
+	if f.Result != "" {
+		clauses = append(clauses, "result eq "+odataString(f.Result))
+	}
+
+	return auditLogQuery(clauses)
+}
+
+// query returns the query parameters for the sign-in log
+func (f SignInFilter) query() string {
+	clauses := timeRangeClauses("createdDateTime", f.From, f.To)
+
+	if f.UserID != "" {
+		clauses = append(clauses, "userId eq "+odataString(f.UserID))
+	}
+	if f.AppID != "" {
+		clauses = append(clauses, "appId eq "+odataString(f.AppID))
+	}
+	switch f.Result {
+	case "success":
+		clauses = append(clauses, "status/errorCode eq 0")
+	case "failure":
+		clauses = append(clauses, "status/errorCode ne 0")
+	}
+
+	return auditLogQuery(clauses)
+}
+
+func timeRangeClauses(property string, from, to time.Time) []string {
+	clauses := []string{}
+	if !from.IsZero() {
+		clauses = append(clauses, property+" ge "+from.UTC().Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		clauses = append(clauses, property+" le "+to.UTC().Format(time.RFC3339))
+	}
+	return clauses
+}
+
+func auditLogQuery(clauses []string) string {
+	param := fmt.Sprintf("$top=%d", auditLogPageSize)
+	if len(clauses) > 0 {
+		param += "&$filter=" + url.QueryEscape(strings.Join(clauses, " and "))
+	}
+	return param
+}
+
+// odataString quotes value as OData string literal
+func odataString(value string) string {
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
@@ -0,0 +1,74 @@
+package tenant
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditFilterQuery(t *testing.T) {
+	filter := AuditFilter{
+		From:     time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC),
+		Activity: "Update user",
+		TargetID: "o'brien",
+		Result:   "success",
+	}
+
+	query, err := url.ParseQuery(filter.query())
+	if err != nil {
+		t.Fatalf("Error parsing query: %s", err)
+	}
+
+	expected := "activityDateTime ge 2020-03-01T00:00:00Z and activityDisplayName eq 'Update user' and targetResources/any(t:t/id eq 'o''brien') and result eq 'success'"
+	if query.Get("$filter") != expected {
+		t.Errorf("Expected filter %q, got %q", expected, query.Get("$filter"))
+	}
+
+	if query, _ := url.ParseQuery((SignInFilter{Result: "failure"}).query()); query.Get("$filter") != "status/errorCode ne 0" {
+		t.Errorf("Unexpected sign-in filter %q", query.Get("$filter"))
+	}
+}
+
+func TestWriteDirectoryAudits(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "" {
+			w.Write([]byte(`{"value": [{"id": "a1", "activityDisplayName": "Add member to group", "activityDateTime": "2020-03-01T10:00:00Z"}],
+				"@odata.nextLink": "` + server.URL + `/beta/auditLogs/directoryAudits?page=2"}`))
+			return
+		}
+		w.Write([]byte(`{"value": [{"id": "a2", "initiatedBy": {"user": {"id": "u1"}}}]}`))
+	}))
+	defer server.Close()
+
+	tn := Tenant{Cloud: Cloud{LoginURL: server.URL + "/", GraphURL: server.URL + "/"}}
+
+	out := &bytes.Buffer{}
+	if err := tn.WriteDirectoryAudits(out, AuditFilter{}); err != nil {
+		t.Fatalf("Error while writing audit log: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"id":"a1"`) || !strings.Contains(lines[1], `"user":{"id":"u1"`) {
+		t.Errorf("Unexpected JSON lines: %s", out.String())
+	}
+}
+
+func TestGetDirectoryAudits(t *testing.T) {
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
+
+	if err := tn.GetGraphAccessToken(); err != nil {
+		t.Errorf("Error while obtaining access token: %s", err)
+	}
+
+	if _, err := tn.GetDirectoryAudits(AuditFilter{From: time.Now().Add(-24 * time.Hour)}); err != nil {
+		t.Errorf("Error while reading directory audits: %s", err)
+	}
+}
@@ -0,0 +1,351 @@
+package tenant
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxBatchSize is the maximum number of sub-requests the Microsoft Graph API accepts in one $batch request
+const maxBatchSize = 20
+
+// maxBatchRetries is how often a throttled sub-request is retried individually before giving up
+const maxBatchRetries = 3
+
+// batchRetryDelay is the delay before retrying a throttled sub-request that didn't send a Retry-After header
+var batchRetryDelay = 2 * time.Second
+
+// BatchRequest is a single sub-request of a JSON batch
+type BatchRequest struct {
+	ID        string            `json:"id"`
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Body      json.RawMessage   `json:"body,omitempty"`
+	DependsOn []string          `json:"dependsOn,omitempty"`
+}
+
+// BatchResponse is a single sub-response of a JSON batch as returned by the API
+type BatchResponse struct {
+	ID      string            `json:"id"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+// BatchResult is the outcome of a single sub-request, Err is an *APIError if the sub-request failed
+type BatchResult struct {
+	ID     string
+	Status int
+	Body   json.RawMessage
+	Err    error
+}
+
+// Decode unmarshals the body of a successful sub-request into v
+func (r BatchResult) Decode(v interface{}) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	return json.Unmarshal(r.Body, v)
+}
+
+// Batch collects sub-requests for the Microsoft Graph $batch endpoint.
+// Create it with Tenant.NewBatch, queue requests with Add and send them with Execute.
+type Batch struct {
+	tenant   Tenant
+	requests []BatchRequest
+}
+
+type batchPayload struct {
+	Requests []BatchRequest `json:"requests"`
+}
+
+type batchResponsePayload struct {
+	Responses []BatchResponse `json:"responses"`
+}
+
+// NewBatch returns an empty Batch that is executed with the access token of t
+func (t Tenant) NewBatch() *Batch {
+	return &Batch{tenant: t}
+}
+
+// Add queues a sub-request and returns its ID, which can be used in dependsOn of later requests
+// and to look up the result. url is relative to the API version, e.g. "/users/<objectId>".
+// body is marshaled to JSON unless it is nil.
+func (b *Batch) Add(method, url string, body interface{}, dependsOn ...string) (string, error) {
+	req := BatchRequest{
+		ID:        strconv.Itoa(len(b.requests) + 1),
+		Method:    method,
+		URL:       url,
+		DependsOn: dependsOn,
+	}
+
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return "", fmt.Errorf("error marshaling body of batch request: %s", err)
+		}
+		req.Body = bodyBytes
+		req.Headers = map[string]string{"Content-Type": "application/json"}
+	}
+
+	for _, id := range dependsOn {
+		if b.index(id) < 0 {
+			return "", fmt.Errorf("batch request %s depends on unknown request %s", req.ID, id)
+		}
+	}
+
+	b.requests = append(b.requests, req)
+
+	return req.ID, nil
+}
+
+// Len returns the number of queued sub-requests
+func (b *Batch) Len() int {
+	return len(b.requests)
+}
+
+// Execute sends all queued sub-requests, split into as many $batch calls as needed, and returns the results by request ID.
+// Requests depending on each other are always sent in the same $batch call.
+// Throttled sub-requests are retried individually, followed by the requests that failed because they depend on them.
+// The error is only set if a $batch call as a whole failed, failures of sub-requests are reported in their BatchResult.
+func (b *Batch) Execute() (map[string]BatchResult, error) {
+	chunks, err := b.chunks()
+	if err != nil {
+		return nil, err
+	}
+
+	results := map[string]BatchResult{}
+
+	for _, chunk := range chunks {
+		parameter, err := json.Marshal(batchPayload{Requests: chunk})
+		if err != nil {
+			return results, fmt.Errorf("error marshaling batch: %s", err)
+		}
+
+		response, err := b.tenant.callNewGraphAPI("/$batch", "POST", string(parameter))
+		if err != nil {
+			return results, fmt.Errorf("error while sending batch: %s\n%s", err, string(response))
+		}
+
+		brp := batchResponsePayload{}
+
+		err = json.Unmarshal(response, &brp)
+		if err != nil {
+			return results, fmt.Errorf("error unmarshaling JSON response: %s", err)
+		}
+
+		responses := map[string]BatchResponse{}
+		for _, resp := range brp.Responses {
+			responses[resp.ID] = resp
+		}
+
+		// requests only depend on earlier ones, so in chunk order the dependencies are retried before their dependents
+		resent := map[string]bool{}
+
+		for _, req := range chunk {
+			resp, ok := responses[req.ID]
+			if !ok {
+				results[req.ID] = BatchResult{ID: req.ID, Err: fmt.Errorf("no response for batch request %s", req.ID)}
+				continue
+			}
+
+			if resp.Status == http.StatusTooManyRequests || (resp.Status == http.StatusFailedDependency && b.dependenciesResent(req, resent, results)) {
+				results[req.ID] = b.retry(req, resp)
+				resent[req.ID] = true
+				continue
+			}
+
+			results[req.ID] = newBatchResult(resp)
+		}
+
+		for _, req := range chunk {
+			if userID := memberGroupsUser(req); userID != "" && results[req.ID].Err == nil {
+				b.tenant.cacheDelete(memberGroupsCacheKey + userID)
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// memberGroupsUser returns the objectId of the user whose group memberships are changed by req,
+// i.e. POST /groups/{id}/members/$ref and DELETE /groups/{id}/members/{userId}/$ref, or "" for all other requests
+func memberGroupsUser(req BatchRequest) string {
+	path := strings.SplitN(req.URL, "?", 2)[0]
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	if len(parts) < 4 || parts[0] != "groups" || parts[2] != "members" || parts[len(parts)-1] != "$ref" {
+		return ""
+	}
+
+	switch {
+	case req.Method == "DELETE" && len(parts) == 5:
+		return parts[3]
+	case req.Method == "POST" && len(parts) == 4:
+		ref := struct {
+			ID string `json:"@odata.id"`
+		}{}
+		if err := json.Unmarshal(req.Body, &ref); err != nil {
+			return ""
+		}
+		return ref.ID[strings.LastIndex(ref.ID, "/")+1:]
+	}
+
+	return ""
+}
+
+// dependenciesResent returns true if a request that failed because of its dependencies can be sent again,
+// i.e. at least one of them was throttled and all of them succeeded when they were sent again
+func (b *Batch) dependenciesResent(req BatchRequest, resent map[string]bool, results map[string]BatchResult) bool {
+	anyResent := false
+
+	for _, id := range req.DependsOn {
+		if results[id].Err != nil {
+			return false
+		}
+		anyResent = anyResent || resent[id]
+	}
+
+	return anyResent
+}
+
+// retry sends a throttled sub-request, or one whose dependencies were throttled, on its own until it succeeds
+// or maxBatchRetries is reached
+func (b *Batch) retry(req BatchRequest, resp BatchResponse) BatchResult {
+	result := newBatchResult(resp)
+
+	delay := time.Duration(0)
+	if resp.Status == http.StatusTooManyRequests {
+		delay = retryAfter(resp.Headers)
+	}
+
+	header := http.Header{}
+	contentType := ""
+	for key, value := range req.Headers {
+		if http.CanonicalHeaderKey(key) == "Content-Type" {
+			contentType = value
+			continue
+		}
+		header.Set(key, value)
+	}
+
+	// the batch retries throttled requests itself, sendContentStatus must not retry them again
+	tn := b.tenant
+	tn.MaxRetries = 0
+
+	for i := 0; i < maxBatchRetries; i++ {
+		time.Sleep(delay)
+
+		body, status, err := tn.sendContentStatus(req.Method, b.tenant.graphURL()+req.URL, header, contentType, req.Body)
+		if err == nil {
+			return BatchResult{ID: req.ID, Status: status, Body: body}
+		}
+
+		result.Err = err
+		if apiErr, ok := err.(*APIError); ok {
+			result.Status = apiErr.StatusCode
+			result.Body = apiErr.Body
+			if apiErr.StatusCode == http.StatusTooManyRequests {
+				delay = batchRetryDelay
+				continue
+			}
+		}
+		break
+	}
+
+	return result
+}
+
+// chunks splits the queued requests into $batch sized chunks, keeping dependent requests together
+func (b *Batch) chunks() ([][]BatchRequest, error) {
+	// union-find over dependsOn, every set of connected requests has to go into the same chunk
+	parent := make([]int, len(b.requests))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i, req := range b.requests {
+		for _, id := range req.DependsOn {
+			parent[find(i)] = find(b.index(id))
+		}
+	}
+
+	// collect the sets in the order of their first request
+	sets := [][]BatchRequest{}
+	setIndex := map[int]int{}
+	for i, req := range b.requests {
+		root := find(i)
+		if _, ok := setIndex[root]; !ok {
+			setIndex[root] = len(sets)
+			sets = append(sets, nil)
+		}
+		sets[setIndex[root]] = append(sets[setIndex[root]], req)
+	}
+
+	chunks := [][]BatchRequest{}
+	current := []BatchRequest{}
+
+	for _, set := range sets {
+		if len(set) > maxBatchSize {
+			return nil, fmt.Errorf("batch request %s has a chain of %d dependent requests, at most %d are allowed", set[0].ID, len(set), maxBatchSize)
+		}
+
+		if len(current)+len(set) > maxBatchSize {
+			chunks = append(chunks, current)
+			current = []BatchRequest{}
+		}
+		current = append(current, set...)
+	}
+
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+
+	return chunks, nil
+}
+
+// index returns the position of the request with the given ID or -1
+func (b *Batch) index(id string) int {
+	for i, req := range b.requests {
+		if req.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func newBatchResult(resp BatchResponse) BatchResult {
+	result := BatchResult{ID: resp.ID, Status: resp.Status, Body: resp.Body}
+
+	if resp.Status > 204 {
+		result.Err = &APIError{
+			StatusCode: resp.Status,
+			Status:     fmt.Sprintf("%d %s", resp.Status, http.StatusText(resp.Status)),
+			Body:       resp.Body,
+		}
+	}
+
+	return result
+}
+
+// retryAfter returns the delay requested by the Retry-After header of a sub-response
+func retryAfter(headers map[string]string) time.Duration {
+	for key, value := range headers {
+		if http.CanonicalHeaderKey(key) == "Retry-After" {
+			return parseRetryAfter(value, batchRetryDelay)
+		}
+	}
+	return batchRetryDelay
+}
@@ -0,0 +1,142 @@
+package tenant
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestBatchChunks(t *testing.T) {
+	b := Tenant{}.NewBatch()
+
+	// 19 independent requests, followed by a chain of 3 that doesn't fit into the first chunk anymore
+	for i := 0; i < 19; i++ {
+		if _, err := b.Add("GET", "/users", nil); err != nil {
+			t.Fatalf("Error while adding request: %s", err)
+		}
+	}
+
+	first, _ := b.Add("GET", "/groups", nil)
+	second, _ := b.Add("GET", "/groups", nil, first)
+	if _, err := b.Add("GET", "/groups", nil, second); err != nil {
+		t.Fatalf("Error while adding dependent request: %s", err)
+	}
+
+	chunks, err := b.chunks()
+	if err != nil {
+		t.Fatalf("Error while splitting batch: %s", err)
+	}
+
+	if len(chunks) != 2 || len(chunks[0]) != 19 || len(chunks[1]) != 3 {
+		t.Errorf("Expected chunks of 19 and 3 requests, got %d chunks", len(chunks))
+	}
+
+	if _, err := b.Add("GET", "/groups", nil, "unknown"); err == nil {
+		t.Errorf("Expected error for dependency on unknown request")
+	}
+}
+
+func TestBatchRetry(t *testing.T) {
+	created := ""
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /beta/$batch":
+			w.Write([]byte(`{"responses": [
+				{"id": "1", "status": 429, "headers": {"Retry-After": "0"}},
+				{"id": "2", "status": 424},
+				{"id": "3", "status": 404, "body": {"error": {"code": "Request_ResourceNotFound"}}}
+			]}`))
+		case "POST /beta/groups":
+			body, _ := ioutil.ReadAll(r.Body)
+			created = string(body)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": "g1"}`))
+		case "PATCH /beta/groups/g1":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	b := Tenant{Cloud: Cloud{LoginURL: server.URL + "/", GraphURL: server.URL + "/"}}.NewBatch()
+	first, _ := b.Add("POST", "/groups", map[string]string{"displayName": "group"})
+	second, _ := b.Add("PATCH", "/groups/g1", map[string]string{"description": "group"}, first)
+	third, _ := b.Add("GET", "/groups/unknown", nil)
+	fourth, _ := b.Add("GET", "/groups/unanswered", nil)
+
+	results, err := b.Execute()
+	if err != nil {
+		t.Fatalf("Error while executing batch: %s", err)
+	}
+
+	if results[first].Err != nil || results[first].Status != http.StatusCreated || created != `{"displayName":"group"}` {
+		t.Errorf("Unexpected result of throttled request %+v, sent %s", results[first], created)
+	}
+
+	if results[second].Err != nil || results[second].Status != http.StatusNoContent {
+		t.Errorf("Expected dependent request to be sent again, got %+v", results[second])
+	}
+
+	if results[third].Status != http.StatusNotFound || results[third].Err == nil {
+		t.Errorf("Unexpected result of failed request %+v", results[third])
+	}
+
+	if result, ok := results[fourth]; !ok || result.Err == nil {
+		t.Errorf("Expected an error for the request without response, got %+v", result)
+	}
+}
+
+func TestBatchExecute(t *testing.T) {
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
+
+	if err := tn.GetGraphAccessToken(); err != nil {
+		t.Errorf("Error while obtaining access token: %s", err)
+	}
+
+	userObjectID := os.Getenv("B2C_TESTUSER")
+
+	b := tn.NewBatch()
+	id, err := b.Add("GET", "/users/"+userObjectID, nil)
+	if err != nil {
+		t.Fatalf("Error while adding request: %s", err)
+	}
+
+	results, err := b.Execute()
+	if err != nil {
+		t.Fatalf("Error while executing batch: %s", err)
+	}
+
+	user := User{}
+	if err := results[id].Decode(&user); err != nil {
+		t.Errorf("Error while reading user: %s", err)
+	}
+
+	if user.ID != userObjectID {
+		t.Errorf("Object ID of returned user is %s, should be: %s", user.ID, userObjectID)
+	}
+}
+
+func TestBatchMemberGroupsUser(t *testing.T) {
+	tests := []struct {
+		req  BatchRequest
+		user string
+	}{
+		{BatchRequest{Method: "POST", URL: "/groups/g1/members/$ref", Body: []byte(`{"@odata.id": "https://graph.microsoft.com/v1.0/directoryObjects/u1"}`)}, "u1"},
+		{BatchRequest{Method: "DELETE", URL: "/groups/g1/members/u2/$ref"}, "u2"},
+		{BatchRequest{Method: "GET", URL: "/groups/g1/members"}, ""},
+		{BatchRequest{Method: "GET", URL: "/users/u1"}, ""},
+	}
+
+	for _, test := range tests {
+		if user := memberGroupsUser(test.req); user != test.user {
+			t.Errorf("Expected user %q for %s %s, got %q", test.user, test.req.Method, test.req.URL, user)
+		}
+	}
+}
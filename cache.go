@@ -0,0 +1,188 @@
+package tenant
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Cache is the interface of the optional caching layer for the read operations of Tenant.
+// Implementations have to be safe for concurrent use, as a single Cache is shared by all copies of a Tenant.
+type Cache interface {
+	// Get returns the value stored for key, if it exists and has not expired yet
+	Get(key string) (interface{}, bool)
+	// Set stores value for key, it expires after ttl
+	Set(key string, value interface{}, ttl time.Duration)
+	// Delete removes key from the cache
+	Delete(key string)
+}
+
+// CacheTTLs contains the time-to-live of the cached entries per resource type
+type CacheTTLs struct {
+	User         time.Duration
+	Group        time.Duration
+	MemberGroups time.Duration
+	// NotFound is the TTL of negative entries, i.e. objects the API responded to with 404
+	NotFound time.Duration
+}
+
+// DefaultCacheTTLs are used for every resource type with a zero TTL in Tenant.CacheTTLs
+var DefaultCacheTTLs = CacheTTLs{
+	User:         5 * time.Minute,
+	Group:        15 * time.Minute,
+	MemberGroups: 5 * time.Minute,
+	NotFound:     time.Minute,
+}
+
+// cache key prefixes of the different resource types
+const (
+	userCacheKey         = "user:"
+	groupCacheKey        = "group:"
+	memberGroupsCacheKey = "membergroups:"
+)
+
+// LRUCache is an in-memory Cache that holds at most a fixed number of entries.
+// When it is full, the least recently used entry is evicted.
+type LRUCache struct {
+	size    int
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// NewLRUCache returns an empty LRUCache holding up to size entries
+func NewLRUCache(size int) *LRUCache {
+	return &LRUCache{
+		size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+// Get returns the value stored for key, if it exists and has not expired yet
+func (c *LRUCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Set stores value for key, it expires after ttl
+func (c *LRUCache) Set(key string, value interface{}, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := time.Now().Add(ttl)
+
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		entry.value = value
+		entry.expires = expires
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// Delete removes key from the cache
+func (c *LRUCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+}
+
+// Len returns the number of entries in the cache, including expired ones that have not been evicted yet
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+// cacheTTLs returns the configured TTLs of t, filled up with DefaultCacheTTLs
+func (t Tenant) cacheTTLs() CacheTTLs {
+	ttls := t.CacheTTLs
+	if ttls.User == 0 {
+		ttls.User = DefaultCacheTTLs.User
+	}
+	if ttls.Group == 0 {
+		ttls.Group = DefaultCacheTTLs.Group
+	}
+	if ttls.MemberGroups == 0 {
+		ttls.MemberGroups = DefaultCacheTTLs.MemberGroups
+	}
+	if ttls.NotFound == 0 {
+		ttls.NotFound = DefaultCacheTTLs.NotFound
+	}
+	return ttls
+}
+
+// cacheGet looks up key in t.Cache. A cached 404 response is returned as error.
+func (t Tenant) cacheGet(key string) (interface{}, bool, error) {
+	if t.Cache == nil {
+		return nil, false, nil
+	}
+
+	value, ok := t.Cache.Get(key)
+	if !ok {
+		return nil, false, nil
+	}
+
+	if apiErr, isErr := value.(*APIError); isErr {
+		return nil, true, apiErr
+	}
+
+	return value, true, nil
+}
+
+// cacheSet stores value in t.Cache if caching is enabled
+func (t Tenant) cacheSet(key string, value interface{}, ttl time.Duration) {
+	if t.Cache != nil {
+		t.Cache.Set(key, value, ttl)
+	}
+}
+
+// cacheSetError stores err as negative entry in t.Cache, if it is a 404 response of the API
+func (t Tenant) cacheSetError(key string, err error) {
+	if apiErr, ok := err.(*APIError); ok && apiErr.StatusCode == http.StatusNotFound {
+		t.cacheSet(key, apiErr, t.cacheTTLs().NotFound)
+	}
+}
+
+// cacheDelete removes key from t.Cache if caching is enabled
+func (t Tenant) cacheDelete(key string) {
+	if t.Cache != nil {
+		t.Cache.Delete(key)
+	}
+}
@@ -0,0 +1,56 @@
+package tenant
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	c := NewLRUCache(2)
+
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Minute)
+
+	// reading a makes b the least recently used entry
+	if v, ok := c.Get("a"); !ok || v.(int) != 1 {
+		t.Errorf("Expected a=1, got %v (found: %t)", v, ok)
+	}
+
+	c.Set("c", 3, time.Minute)
+
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("Expected b to be evicted")
+	}
+
+	if c.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", c.Len())
+	}
+
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Expected a to be deleted")
+	}
+
+	c.Set("d", 4, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	if _, ok := c.Get("d"); ok {
+		t.Errorf("Expected d to be expired")
+	}
+}
+
+func TestNegativeCaching(t *testing.T) {
+	tn := Tenant{Cache: NewLRUCache(10)}
+
+	tn.cacheSetError(groupCacheKey+"missing", &APIError{StatusCode: http.StatusNotFound, Status: "404 Not Found"})
+	tn.cacheSetError(groupCacheKey+"broken", &APIError{StatusCode: http.StatusInternalServerError, Status: "500 Internal Server Error"})
+
+	if _, err := tn.GetGroup("missing"); err == nil {
+		t.Errorf("Expected cached 404 for group missing")
+	}
+
+	if _, ok, _ := tn.cacheGet(groupCacheKey + "broken"); ok {
+		t.Errorf("Expected 500 responses not to be cached")
+	}
+}
@@ -0,0 +1,188 @@
+package tenant
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/pkcs12"
+)
+
+// clientAssertionType is the client_assertion_type of certificate and federated client authentication
+const clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// clientAssertionLifetime is how long a client assertion JWT is valid
+const clientAssertionLifetime = 10 * time.Minute
+
+// ClientCertificate contains the certificate registered for the app registration and its private key.
+// It is used to sign the client assertion JWT instead of sending a ClientSecret.
+type ClientCertificate struct {
+	Certificate *x509.Certificate
+	PrivateKey  *rsa.PrivateKey
+}
+
+// LoadClientCertificate reads a PEM file (.pem) or a PKCS#12 file (any other extension), password is only used for the latter
+func LoadClientCertificate(path, password string) (*ClientCertificate, error) {
+	if strings.EqualFold(filepath.Ext(path), ".pem") {
+		return LoadClientCertificatePEM(path)
+	}
+	return LoadClientCertificatePFX(path, password)
+}
+
+// LoadClientCertificatePEM reads a PEM file containing the certificate and its unencrypted RSA private key
+// (PKCS#1 or PKCS#8)
+func LoadClientCertificatePEM(path string) (*ClientCertificate, error) {
+	pemBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading certificate file: %s", err)
+	}
+
+	return ParseClientCertificatePEM(pemBytes)
+}
+
+// ParseClientCertificatePEM parses PEM data containing the certificate and its unencrypted RSA private key
+func ParseClientCertificatePEM(pemBytes []byte) (*ClientCertificate, error) {
+	cc := &ClientCertificate{}
+
+	for {
+		var block *pem.Block
+		block, pemBytes = pem.Decode(pemBytes)
+		if block == nil {
+			break
+		}
+
+		switch block.Type {
+		case "CERTIFICATE":
+			// the first certificate is the client certificate, the rest is the chain
+			if cc.Certificate != nil {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing certificate: %s", err)
+			}
+			cc.Certificate = cert
+		case "RSA PRIVATE KEY":
+			key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing private key: %s", err)
+			}
+			cc.PrivateKey = key
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing private key: %s", err)
+			}
+			rsaKey, ok := key.(*rsa.PrivateKey)
+			if !ok {
+				return nil, fmt.Errorf("private key is not an RSA key")
+			}
+			cc.PrivateKey = rsaKey
+		}
+	}
+
+	return cc, cc.validate()
+}
+
+// LoadClientCertificatePFX reads a PKCS#12 (.pfx/.p12) file containing the certificate and its RSA private key
+func LoadClientCertificatePFX(path, password string) (*ClientCertificate, error) {
+	pfxBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading certificate file: %s", err)
+	}
+
+	key, cert, err := pkcs12.Decode(pfxBytes, password)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding PFX file: %s", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not an RSA key")
+	}
+
+	cc := &ClientCertificate{Certificate: cert, PrivateKey: rsaKey}
+
+	return cc, cc.validate()
+}
+
+func (cc *ClientCertificate) validate() error {
+	if cc.Certificate == nil {
+		return fmt.Errorf("no certificate found")
+	}
+
+	if cc.PrivateKey == nil {
+		return fmt.Errorf("no private key found")
+	}
+
+	pub, ok := cc.Certificate.PublicKey.(*rsa.PublicKey)
+	if !ok || pub.N.Cmp(cc.PrivateKey.N) != 0 {
+		return fmt.Errorf("private key doesn't match the certificate")
+	}
+
+	return nil
+}
+
+// ClientAssertion returns a signed client assertion JWT for clientID, to be sent to the token endpoint tokenURL
+func (cc *ClientCertificate) ClientAssertion(clientID, tokenURL string) (string, error) {
+	sha1Thumbprint := sha1.Sum(cc.Certificate.Raw)
+	sha256Thumbprint := sha256.Sum256(cc.Certificate.Raw)
+
+	header := map[string]string{
+		"alg":      "RS256",
+		"typ":      "JWT",
+		"x5t":      base64.RawURLEncoding.EncodeToString(sha1Thumbprint[:]),
+		"x5t#S256": base64.RawURLEncoding.EncodeToString(sha256Thumbprint[:]),
+	}
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", fmt.Errorf("error generating jti: %s", err)
+	}
+
+	now := time.Now()
+
+	// the audience is the token endpoint without any query parameters
+	aud := strings.SplitN(tokenURL, "?", 2)[0]
+
+	claims := map[string]interface{}{
+		"aud": aud,
+		"iss": clientID,
+		"sub": clientID,
+		"jti": fmt.Sprintf("%x", jti),
+		"nbf": now.Unix(),
+		"iat": now.Unix(),
+		"exp": now.Add(clientAssertionLifetime).Unix(),
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	hash := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, cc.PrivateKey, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("error signing client assertion: %s", err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,98 @@
+package tenant
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertificatePEM returns a self-signed certificate and its private key in PEM format
+func testCertificatePEM(t *testing.T) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "b2c-tenant test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %s", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	return append(certPEM, keyPEM...)
+}
+
+func TestClientAssertion(t *testing.T) {
+	cc, err := ParseClientCertificatePEM(testCertificatePEM(t))
+	if err != nil {
+		t.Fatalf("Error parsing certificate: %s", err)
+	}
+
+	tokenURL := "https://login.microsoftonline.com/example.onmicrosoft.com/oauth2/token?api-version=1.0"
+
+	assertion, err := cc.ClientAssertion("client-id", tokenURL)
+	if err != nil {
+		t.Fatalf("Error building client assertion: %s", err)
+	}
+
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Expected JWT with 3 parts, got %d", len(parts))
+	}
+
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&cc.PrivateKey.PublicKey, crypto.SHA256, hash[:], signature); err != nil {
+		t.Errorf("Invalid signature: %s", err)
+	}
+
+	header := map[string]string{}
+	headerJSON, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	json.Unmarshal(headerJSON, &header)
+	if header["x5t"] == "" || header["x5t#S256"] == "" {
+		t.Errorf("Expected x5t and x5t#S256 headers, got %v", header)
+	}
+
+	claims := map[string]interface{}{}
+	claimsJSON, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	json.Unmarshal(claimsJSON, &claims)
+	if claims["aud"] != "https://login.microsoftonline.com/example.onmicrosoft.com/oauth2/token" || claims["iss"] != "client-id" || claims["sub"] != "client-id" {
+		t.Errorf("Unexpected claims: %v", claims)
+	}
+}
+
+func TestGetGraphAccessTokenWithCertificate(t *testing.T) {
+	cc, err := LoadClientCertificatePEM(os.Getenv("B2C_CLIENT_CERTIFICATE"))
+	if err != nil {
+		t.Fatalf("Error loading client certificate: %s", err)
+	}
+
+	tn := Tenant{}
+	tn.ClientID = os.Getenv("B2C_CLIENT_ID")
+	tn.ClientCertificate = cc
+	tn.TenantDomain = os.Getenv("B2C_TENANT_DOMAIN")
+
+	if err := tn.GetGraphAccessToken(); err != nil {
+		t.Errorf("Error while obtaining access token: %s", err)
+	}
+}
@@ -0,0 +1,77 @@
+package tenant
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Cloud contains the endpoints of an Azure cloud, all URLs end with a slash
+type Cloud struct {
+	Name        string
+	LoginURL    string
+	GraphURL    string
+	AADGraphURL string
+}
+
+// The Azure clouds known to this package, the zero Cloud is treated as AzurePublicCloud
+var (
+	AzurePublicCloud = Cloud{
+		Name:        "AzurePublic",
+		LoginURL:    "https://login.microsoftonline.com/",
+		GraphURL:    "https://graph.microsoft.com/",
+		AADGraphURL: "https://graph.windows.net/",
+	}
+	AzureChinaCloud = Cloud{
+		Name:        "AzureChina",
+		LoginURL:    "https://login.chinacloudapi.cn/",
+		GraphURL:    "https://microsoftgraph.chinacloudapi.cn/",
+		AADGraphURL: "https://graph.chinacloudapi.cn/",
+	}
+	AzureUSGovernmentCloud = Cloud{
+		Name:        "AzureUSGovernment",
+		LoginURL:    "https://login.microsoftonline.us/",
+		GraphURL:    "https://graph.microsoft.us/",
+		AADGraphURL: "https://graph.windows.net/",
+	}
+)
+
+// defaultGraphVersion is the Microsoft Graph API version used if Tenant.GraphVersion is empty
+const defaultGraphVersion = "beta"
+
+// CloudByName returns the cloud with the given name, e.g. "AzurePublic", case-insensitive.
+// An empty name returns AzurePublicCloud.
+func CloudByName(name string) (Cloud, error) {
+	if name == "" {
+		return AzurePublicCloud, nil
+	}
+
+	for _, cloud := range []Cloud{AzurePublicCloud, AzureChinaCloud, AzureUSGovernmentCloud} {
+		if strings.EqualFold(cloud.Name, name) {
+			return cloud, nil
+		}
+	}
+
+	return Cloud{}, fmt.Errorf("unknown cloud %q", name)
+}
+
+// orDefault returns AzurePublicCloud for the zero Cloud
+func (c Cloud) orDefault() Cloud {
+	if c.LoginURL == "" {
+		return AzurePublicCloud
+	}
+	return c
+}
+
+// cloud returns the cloud the tenant lives in
+func (t Tenant) cloud() Cloud {
+	return t.Cloud.orDefault()
+}
+
+// graphURL returns the base URL of the Microsoft Graph API including the configured version, without trailing slash
+func (t Tenant) graphURL() string {
+	version := t.GraphVersion
+	if version == "" {
+		version = defaultGraphVersion
+	}
+	return t.cloud().GraphURL + version
+}
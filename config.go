@@ -0,0 +1,201 @@
+package tenant
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Config contains everything needed to create a Tenant. It can be read from the environment with ConfigFromEnv
+// or from a YAML or JSON file with LoadConfig.
+//
+// The environment variables and file keys are:
+//
+//	B2C_TENANT_DOMAIN                 tenantDomain               required, e.g. example.onmicrosoft.com
+//	B2C_TENANT_ID                     tenantId                   optional, used for token requests if set
+//	B2C_CLIENT_ID                     clientId                   required unless managedIdentity is set
+//	B2C_CLIENT_SECRET                 clientSecret               one of the credentials is required
+//	B2C_CLIENT_CERTIFICATE            clientCertificate          path of a PEM or PFX file
+//	B2C_CLIENT_CERTIFICATE_PASSWORD   clientCertificatePassword  password of the PFX file
+//	B2C_FEDERATED_TOKEN_FILE          federatedTokenFile         falls back to AZURE_FEDERATED_TOKEN_FILE if no other credential is set
+//	B2C_MANAGED_IDENTITY              managedIdentity            true to use the managed identity, clientId selects a user-assigned one
+//	B2C_CLOUD                         cloud                      AzurePublic (default), AzureChina or AzureUSGovernment
+//	B2C_GRAPH_VERSION                 graphVersion               Microsoft Graph API version, defaults to beta
+//	B2C_MAX_RETRIES                   maxRetries                 retries of throttled API calls, defaults to 0
+//	B2C_REQUESTS_PER_SECOND           requestsPerSecond          rate limit of API calls, defaults to unlimited
+type Config struct {
+	TenantDomain              string  `json:"tenantDomain" yaml:"tenantDomain"`
+	TenantID                  string  `json:"tenantId" yaml:"tenantId"`
+	ClientID                  string  `json:"clientId" yaml:"clientId"`
+	ClientSecret              string  `json:"clientSecret" yaml:"clientSecret"`
+	ClientCertificate         string  `json:"clientCertificate" yaml:"clientCertificate"`
+	ClientCertificatePassword string  `json:"clientCertificatePassword" yaml:"clientCertificatePassword"`
+	FederatedTokenFile        string  `json:"federatedTokenFile" yaml:"federatedTokenFile"`
+	ManagedIdentity           bool    `json:"managedIdentity" yaml:"managedIdentity"`
+	Cloud                     string  `json:"cloud" yaml:"cloud"`
+	GraphVersion              string  `json:"graphVersion" yaml:"graphVersion"`
+	MaxRetries                int     `json:"maxRetries" yaml:"maxRetries"`
+	RequestsPerSecond         float64 `json:"requestsPerSecond" yaml:"requestsPerSecond"`
+}
+
+// ConfigFromEnv reads the Config from the environment variables documented on Config and validates it
+func ConfigFromEnv() (Config, error) {
+	c := Config{
+		TenantDomain:              os.Getenv("B2C_TENANT_DOMAIN"),
+		TenantID:                  os.Getenv("B2C_TENANT_ID"),
+		ClientID:                  os.Getenv("B2C_CLIENT_ID"),
+		ClientSecret:              os.Getenv("B2C_CLIENT_SECRET"),
+		ClientCertificate:         os.Getenv("B2C_CLIENT_CERTIFICATE"),
+		ClientCertificatePassword: os.Getenv("B2C_CLIENT_CERTIFICATE_PASSWORD"),
+		FederatedTokenFile:        os.Getenv("B2C_FEDERATED_TOKEN_FILE"),
+		Cloud:                     os.Getenv("B2C_CLOUD"),
+		GraphVersion:              os.Getenv("B2C_GRAPH_VERSION"),
+	}
+
+	var err error
+
+	if value := os.Getenv("B2C_MANAGED_IDENTITY"); value != "" {
+		if c.ManagedIdentity, err = strconv.ParseBool(value); err != nil {
+			return c, fmt.Errorf("invalid B2C_MANAGED_IDENTITY %q: %s", value, err)
+		}
+	}
+
+	// AZURE_FEDERATED_TOKEN_FILE is injected into every pod using workload identity, so it must not override
+	// an explicitly configured credential
+	if c.FederatedTokenFile == "" && c.ClientSecret == "" && c.ClientCertificate == "" && !c.ManagedIdentity {
+		c.FederatedTokenFile = os.Getenv(FederatedTokenFileEnv)
+	}
+
+	if value := os.Getenv("B2C_MAX_RETRIES"); value != "" {
+		if c.MaxRetries, err = strconv.Atoi(value); err != nil {
+			return c, fmt.Errorf("invalid B2C_MAX_RETRIES %q: %s", value, err)
+		}
+	}
+
+	if value := os.Getenv("B2C_REQUESTS_PER_SECOND"); value != "" {
+		if c.RequestsPerSecond, err = strconv.ParseFloat(value, 64); err != nil {
+			return c, fmt.Errorf("invalid B2C_REQUESTS_PER_SECOND %q: %s", value, err)
+		}
+	}
+
+	return c, c.Validate()
+}
+
+// LoadConfig reads the Config from a YAML (.yaml, .yml) or JSON file and validates it
+func LoadConfig(path string) (Config, error) {
+	c := Config{}
+
+	configBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return c, fmt.Errorf("error reading config file: %s", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(configBytes, &c)
+	case ".json":
+		decoder := json.NewDecoder(strings.NewReader(string(configBytes)))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&c)
+	default:
+		return c, fmt.Errorf("unsupported config file %s, must be .yaml, .yml or .json", path)
+	}
+
+	if err != nil {
+		return c, fmt.Errorf("error parsing config file %s: %s", path, err)
+	}
+
+	return c, c.Validate()
+}
+
+// Validate returns an error listing all missing or invalid settings
+func (c Config) Validate() error {
+	problems := []string{}
+
+	if c.TenantDomain == "" {
+		problems = append(problems, "tenant domain is missing")
+	}
+
+	if c.ClientID == "" && !c.ManagedIdentity {
+		problems = append(problems, "client ID is missing")
+	}
+
+	if c.ClientSecret == "" && c.ClientCertificate == "" && c.FederatedTokenFile == "" && !c.ManagedIdentity {
+		problems = append(problems, "no credential configured, need a client secret, client certificate, federated token file or managed identity")
+	}
+
+	if c.FederatedTokenFile != "" && (c.ClientSecret != "" || c.ClientCertificate != "") {
+		problems = append(problems, "ambiguous credentials, a federated token file can't be combined with a client secret or client certificate")
+	}
+
+	if _, err := CloudByName(c.Cloud); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if c.MaxRetries < 0 {
+		problems = append(problems, "max retries must not be negative")
+	}
+
+	if c.RequestsPerSecond < 0 {
+		problems = append(problems, "requests per second must not be negative")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// NewTenant creates a Tenant from a validated Config. It doesn't obtain an access token yet.
+func NewTenant(c Config) (Tenant, error) {
+	if err := c.Validate(); err != nil {
+		return Tenant{}, err
+	}
+
+	cloud, _ := CloudByName(c.Cloud)
+
+	t := Tenant{
+		ClientID:           c.ClientID,
+		ClientSecret:       c.ClientSecret,
+		TenantDomain:       c.TenantDomain,
+		TenantID:           c.TenantID,
+		FederatedTokenFile: c.FederatedTokenFile,
+		Cloud:              cloud,
+		GraphVersion:       c.GraphVersion,
+		MaxRetries:         c.MaxRetries,
+	}
+
+	if c.ManagedIdentity {
+		t.ManagedIdentity = &ManagedIdentity{ClientID: c.ClientID}
+	}
+
+	if c.ClientCertificate != "" {
+		var err error
+		t.ClientCertificate, err = LoadClientCertificate(c.ClientCertificate, c.ClientCertificatePassword)
+		if err != nil {
+			return Tenant{}, err
+		}
+	}
+
+	if c.RequestsPerSecond > 0 {
+		t.RateLimiter = NewRateLimiter(c.RequestsPerSecond)
+	}
+
+	return t, nil
+}
+
+// NewTenantFromEnv creates a Tenant from the environment variables documented on Config
+func NewTenantFromEnv() (Tenant, error) {
+	c, err := ConfigFromEnv()
+	if err != nil {
+		return Tenant{}, err
+	}
+	return NewTenant(c)
+}
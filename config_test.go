@@ -0,0 +1,103 @@
+package tenant
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "b2c-tenant")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"config.yaml": "tenantDomain: example.onmicrosoft.com\nclientId: client-id\nclientSecret: secret\ncloud: AzureChina\nmaxRetries: 3\nrequestsPerSecond: 5\n",
+		"config.json": `{"tenantDomain": "example.onmicrosoft.com", "clientId": "client-id", "clientSecret": "secret", "cloud": "AzureChina", "maxRetries": 3, "requestsPerSecond": 5}`,
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Error writing config file: %s", err)
+		}
+
+		c, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("Error loading %s: %s", name, err)
+		}
+
+		tn, err := NewTenant(c)
+		if err != nil {
+			t.Fatalf("Error creating tenant from %s: %s", name, err)
+		}
+
+		if tn.TenantDomain != "example.onmicrosoft.com" || tn.Cloud.Name != "AzureChina" || tn.MaxRetries != 3 || tn.RateLimiter == nil {
+			t.Errorf("Unexpected tenant from %s: %+v", name, tn)
+		}
+	}
+
+	// unknown keys are most likely typos and must not be ignored
+	path := filepath.Join(dir, "typo.yaml")
+	ioutil.WriteFile(path, []byte("tenantDomian: example.onmicrosoft.com\n"), 0600)
+
+	if _, err := LoadConfig(path); err == nil {
+		t.Errorf("Expected error for unknown key")
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	err := Config{Cloud: "Mars"}.Validate()
+	if err == nil {
+		t.Fatalf("Expected error for empty config")
+	}
+
+	for _, problem := range []string{"tenant domain", "client ID", "no credential", "unknown cloud"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Expected %q to be reported, got: %s", problem, err)
+		}
+	}
+
+	if err := (Config{TenantDomain: "example.onmicrosoft.com", ManagedIdentity: true}).Validate(); err != nil {
+		t.Errorf("Expected managed identity config to be valid, got: %s", err)
+	}
+
+	err = Config{TenantDomain: "example.onmicrosoft.com", ClientID: "id", ClientSecret: "secret", FederatedTokenFile: "/token"}.Validate()
+	if err == nil || !strings.Contains(err.Error(), "ambiguous credentials") {
+		t.Errorf("Expected ambiguous credentials to be reported, got: %v", err)
+	}
+}
+
+func TestConfigFromEnvFederatedFallback(t *testing.T) {
+	// the live tests read their configuration from the same variables, so restore them afterwards
+	env := map[string]string{
+		"B2C_TENANT_DOMAIN":        "example.onmicrosoft.com",
+		"B2C_CLIENT_ID":            "id",
+		"B2C_CLIENT_SECRET":        "secret",
+		"B2C_CLIENT_CERTIFICATE":   "",
+		"B2C_FEDERATED_TOKEN_FILE": "",
+		"B2C_MANAGED_IDENTITY":     "",
+		FederatedTokenFileEnv:      "/token",
+	}
+	for key, value := range env {
+		if old, ok := os.LookupEnv(key); ok {
+			defer os.Setenv(key, old)
+		} else {
+			defer os.Unsetenv(key)
+		}
+		os.Setenv(key, value)
+	}
+
+	c, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading config: %s", err)
+	}
+
+	if c.FederatedTokenFile != "" {
+		t.Errorf("Expected %s to be ignored if a client secret is set, got %q", FederatedTokenFileEnv, c.FederatedTokenFile)
+	}
+}
@@ -0,0 +1,240 @@
+// Package connector provides a framework for API connector endpoints of B2C sign-up user flows.
+//
+// B2C calls API connectors after federating with an identity provider and before creating the user.
+// Register functions that validate the request or return additional claims and protect the handler with
+// the authentication configured for the API connector, e.g.
+//
+//	h := connector.NewHandler(connector.EmailDomain("example.com"), enrichFromCRM)
+//	http.Handle("/connector", middleware.BasicAuth(user, password)(h))
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// responseVersion is the API connector response format version
+const responseVersion = "1.0.0"
+
+// Steps of the user flow an API connector can be called in
+const (
+	StepPostFederationSignup    = "PostFederationSignup"
+	StepPostAttributeCollection = "PostAttributeCollection"
+	StepPreTokenIssuance        = "PreTokenIssuance"
+)
+
+// Action tells B2C how to continue the user flow
+type Action string
+
+// The actions an API connector can respond with
+const (
+	ActionContinue        Action = "Continue"
+	ActionShowBlockPage   Action = "ShowBlockPage"
+	ActionValidationError Action = "ValidationError"
+)
+
+// Identity is an identity of the user at an identity provider
+type Identity struct {
+	SignInType       string `json:"signInType"`
+	Issuer           string `json:"issuer"`
+	IssuerAssignedID string `json:"issuerAssignedId"`
+}
+
+// Request is the request B2C sends to an API connector. The built-in claims are available as fields,
+// all claims including custom attributes (extension_<appId>_<name>) are in Claims.
+type Request struct {
+	Step        string     `json:"step"`
+	ClientID    string     `json:"client_id"`
+	UILocales   string     `json:"ui_locales"`
+	Email       string     `json:"email"`
+	Identities  []Identity `json:"identities"`
+	DisplayName string     `json:"displayName"`
+	GivenName   string     `json:"givenName"`
+	Surname     string     `json:"surname"`
+
+	Claims map[string]interface{} `json:"-"`
+}
+
+// Claim returns a claim of the request as string, e.g. a custom attribute, or "" if it isn't set
+func (r *Request) Claim(name string) string {
+	value, ok := r.Claims[name]
+	if !ok || value == nil {
+		return ""
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+// Response is the result of a Func
+type Response struct {
+	Action Action
+	// UserMessage is shown to the user for ShowBlockPage and ValidationError
+	UserMessage string
+	// Claims are returned to B2C with Continue, they overwrite the claims collected by the user flow
+	Claims map[string]interface{}
+}
+
+// Continue returns a response that continues the user flow with the given claims, which may be nil
+func Continue(claims map[string]interface{}) *Response {
+	return &Response{Action: ActionContinue, Claims: claims}
+}
+
+// Block returns a response that shows a block page with message and ends the user flow
+func Block(message string) *Response {
+	return &Response{Action: ActionShowBlockPage, UserMessage: message}
+}
+
+// ValidationError returns a response that shows message on the attribute collection page so the user can correct the input.
+// It is only supported in the PostAttributeCollection step.
+func ValidationError(message string) *Response {
+	return &Response{Action: ActionValidationError, UserMessage: message}
+}
+
+// Func validates or enriches a request. Returning nil continues with the next Func.
+// An error blocks the user flow with Handler.ErrorMessage.
+type Func func(ctx context.Context, req *Request) (*Response, error)
+
+// Handler runs the registered functions in order for each request. The claims of Continue responses are
+// merged and visible to the following functions in req.Claims. The first ShowBlockPage or ValidationError
+// response is returned to B2C right away.
+type Handler struct {
+	funcs []Func
+	// ErrorMessage is shown on the block page if a Func returns an error
+	ErrorMessage string
+}
+
+// NewHandler returns a Handler running funcs
+func NewHandler(funcs ...Func) *Handler {
+	return &Handler{
+		funcs:        funcs,
+		ErrorMessage: "There was a problem with your sign-up, please try again later.",
+	}
+}
+
+// Register adds f to the functions run for each request
+func (h *Handler) Register(f Func) {
+	h.funcs = append(h.funcs, f)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "only POST requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, err := decodeRequest(r)
+	if err != nil {
+		log.Printf("invalid API connector request: %s", err)
+		writeResponse(w, Block(h.ErrorMessage))
+		return
+	}
+
+	claims := map[string]interface{}{}
+
+	for _, f := range h.funcs {
+		resp, err := f(r.Context(), req)
+		if err != nil {
+			log.Printf("API connector function failed in step %s: %s", req.Step, err)
+			writeResponse(w, Block(h.ErrorMessage))
+			return
+		}
+
+		if resp == nil {
+			continue
+		}
+
+		if resp.Action != ActionContinue {
+			writeResponse(w, resp)
+			return
+		}
+
+		for name, value := range resp.Claims {
+			claims[name] = value
+			req.Claims[name] = value
+		}
+	}
+
+	writeResponse(w, Continue(claims))
+}
+
+// EmailDomain returns a Func that rejects sign-ups whose email address is not in one of the domains
+func EmailDomain(domains ...string) Func {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		at := strings.LastIndex(req.Email, "@")
+		if at < 0 {
+			if req.Step == StepPostAttributeCollection {
+				return ValidationError("Please enter a valid email address."), nil
+			}
+			return Block("Sign-up requires a valid email address."), nil
+		}
+
+		domain := req.Email[at+1:]
+		for _, allowed := range domains {
+			if strings.EqualFold(domain, allowed) {
+				return nil, nil
+			}
+		}
+
+		if req.Step == StepPostAttributeCollection {
+			return ValidationError("Sign-up is only allowed with an email address of " + strings.Join(domains, ", ") + "."), nil
+		}
+		return Block("Sign-up is only allowed with an email address of " + strings.Join(domains, ", ") + "."), nil
+	}
+}
+
+func decodeRequest(r *http.Request) (*Request, error) {
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &Request{}
+	if err := json.Unmarshal(bodyBytes, req); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(bodyBytes, &req.Claims); err != nil {
+		return nil, err
+	}
+
+	// a body of null leaves the claims nil, but the functions' claims are merged into them
+	if req.Claims == nil {
+		req.Claims = map[string]interface{}{}
+	}
+
+	return req, nil
+}
+
+// writeResponse sends resp in the API connector response format, validation errors are sent with status 400
+func writeResponse(w http.ResponseWriter, resp *Response) {
+	payload := map[string]interface{}{}
+
+	for name, value := range resp.Claims {
+		payload[name] = value
+	}
+
+	payload["version"] = responseVersion
+	payload["action"] = resp.Action
+
+	status := http.StatusOK
+
+	if resp.Action != ActionContinue {
+		payload["userMessage"] = resp.UserMessage
+	}
+
+	if resp.Action == ActionValidationError {
+		status = http.StatusBadRequest
+		payload["status"] = status
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(payload)
+}
@@ -0,0 +1,79 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func call(h http.Handler, body string) (int, map[string]interface{}) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+
+	response := map[string]interface{}{}
+	json.NewDecoder(rec.Body).Decode(&response)
+
+	return rec.Code, response
+}
+
+func TestHandler(t *testing.T) {
+	enrich := func(ctx context.Context, req *Request) (*Response, error) {
+		return Continue(map[string]interface{}{"extension_customerId": "c-" + req.Claim("extension_accountNumber")}), nil
+	}
+
+	// the second function sees the claims of the first one
+	check := func(ctx context.Context, req *Request) (*Response, error) {
+		if req.Claim("extension_customerId") == "" {
+			return nil, fmt.Errorf("customer ID is missing")
+		}
+		return nil, nil
+	}
+
+	h := NewHandler(EmailDomain("example.com"), enrich, check)
+
+	status, response := call(h, `{"step": "PostAttributeCollection", "email": "user@example.com", "extension_accountNumber": 42}`)
+	if status != http.StatusOK || response["action"] != "Continue" || response["version"] != "1.0.0" || response["extension_customerId"] != "c-42" {
+		t.Errorf("Unexpected response %d %v", status, response)
+	}
+
+	status, response = call(h, `{"step": "PostAttributeCollection", "email": "user@other.com"}`)
+	if status != http.StatusBadRequest || response["action"] != "ValidationError" || response["status"] != float64(400) || response["userMessage"] == "" {
+		t.Errorf("Unexpected response %d %v", status, response)
+	}
+
+	status, response = call(h, `{"step": "PostFederationSignup", "email": "user@other.com"}`)
+	if status != http.StatusOK || response["action"] != "ShowBlockPage" {
+		t.Errorf("Unexpected response %d %v", status, response)
+	}
+
+	// a missing email can only be corrected on the attribute collection page
+	status, response = call(h, `{"step": "PostAttributeCollection"}`)
+	if status != http.StatusBadRequest || response["action"] != "ValidationError" {
+		t.Errorf("Unexpected response %d %v", status, response)
+	}
+
+	status, response = call(h, `{"step": "PreTokenIssuance"}`)
+	if status != http.StatusOK || response["action"] != "ShowBlockPage" {
+		t.Errorf("Unexpected response %d %v", status, response)
+	}
+
+	// a request without claims doesn't break merging the claims of the functions
+	status, response = call(NewHandler(enrich), `null`)
+	if status != http.StatusOK || response["action"] != "Continue" || response["extension_customerId"] != "c-" {
+		t.Errorf("Unexpected response %d %v", status, response)
+	}
+
+	// errors and invalid requests block the user flow
+	h.Register(func(ctx context.Context, req *Request) (*Response, error) { return nil, fmt.Errorf("CRM down") })
+
+	for _, body := range []string{`{"email": "user@example.com"}`, `not json`} {
+		status, response = call(h, body)
+		if status != http.StatusOK || response["action"] != "ShowBlockPage" || response["userMessage"] != h.ErrorMessage {
+			t.Errorf("Unexpected response %d %v", status, response)
+		}
+	}
+}
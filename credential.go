@@ -0,0 +1,261 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Token is an access token obtained by a TokenCredential
+type Token struct {
+	AccessToken string
+	TokenType   string
+	ExpiresOn   time.Time
+}
+
+// TokenCredential obtains access tokens for the given scopes, e.g. "https://graph.microsoft.com/.default".
+// Implement it to supply tokens from other sources, e.g. secrets stored in Key Vault, and set it as Tenant.Credential.
+type TokenCredential interface {
+	GetToken(ctx context.Context, scopes []string) (Token, error)
+}
+
+// tokenResponse is the response of the token endpoints, expires_in is sent by Azure AD and expires_on by managed identity endpoints
+type tokenResponse struct {
+	AccessToken string      `json:"access_token"`
+	TokenType   string      `json:"token_type"`
+	ExpiresIn   json.Number `json:"expires_in"`
+	ExpiresOn   json.Number `json:"expires_on"`
+}
+
+func (tr tokenResponse) token() Token {
+	tok := Token{AccessToken: tr.AccessToken, TokenType: tr.TokenType}
+
+	if tok.TokenType == "" {
+		tok.TokenType = "Bearer"
+	}
+
+	if expiresOn, err := tr.ExpiresOn.Int64(); err == nil {
+		tok.ExpiresOn = time.Unix(expiresOn, 0)
+	} else if expiresIn, err := tr.ExpiresIn.Int64(); err == nil {
+		tok.ExpiresOn = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+
+	return tok
+}
+
+// ClientSecretCredential authenticates an app registration with its client secret
+type ClientSecretCredential struct {
+	TenantDomain string
+	ClientID     string
+	ClientSecret string
+	// Cloud defaults to AzurePublicCloud
+	Cloud Cloud
+}
+
+// GetToken requests a token from the v2.0 token endpoint of the tenant
+func (c ClientSecretCredential) GetToken(ctx context.Context, scopes []string) (Token, error) {
+	parameters := url.Values{
+		"client_id":     {c.ClientID},
+		"client_secret": {c.ClientSecret},
+	}
+
+	return requestToken(ctx, c.Cloud, c.TenantDomain, parameters, scopes)
+}
+
+// ClientCertificateCredential authenticates an app registration with a client assertion signed by its certificate
+type ClientCertificateCredential struct {
+	TenantDomain string
+	ClientID     string
+	Certificate  *ClientCertificate
+	// Cloud defaults to AzurePublicCloud
+	Cloud Cloud
+}
+
+// GetToken requests a token from the v2.0 token endpoint of the tenant
+func (c ClientCertificateCredential) GetToken(ctx context.Context, scopes []string) (Token, error) {
+	assertion, err := c.Certificate.ClientAssertion(c.ClientID, tokenURL(c.Cloud, c.TenantDomain))
+	if err != nil {
+		return Token{}, err
+	}
+
+	parameters := url.Values{
+		"client_id":             {c.ClientID},
+		"client_assertion_type": {clientAssertionType},
+		"client_assertion":      {assertion},
+	}
+
+	return requestToken(ctx, c.Cloud, c.TenantDomain, parameters, scopes)
+}
+
+// FederatedCredential authenticates an app registration with a federated identity token read from TokenFile.
+// The file is read again for every token request, as the platform rotates it.
+type FederatedCredential struct {
+	TenantDomain string
+	ClientID     string
+	TokenFile    string
+	// Cloud defaults to AzurePublicCloud
+	Cloud Cloud
+}
+
+// GetToken requests a token from the v2.0 token endpoint of the tenant
+func (c FederatedCredential) GetToken(ctx context.Context, scopes []string) (Token, error) {
+	assertion, err := readFederatedToken(c.TokenFile)
+	if err != nil {
+		return Token{}, err
+	}
+
+	parameters := url.Values{
+		"client_id":             {c.ClientID},
+		"client_assertion_type": {clientAssertionType},
+		"client_assertion":      {assertion},
+	}
+
+	return requestToken(ctx, c.Cloud, c.TenantDomain, parameters, scopes)
+}
+
+// StaticTokenCredential always returns the same token, e.g. one that was obtained outside of this package
+type StaticTokenCredential struct {
+	Token Token
+}
+
+// GetToken returns the static token unless it has expired
+func (c StaticTokenCredential) GetToken(ctx context.Context, scopes []string) (Token, error) {
+	if !c.Token.ExpiresOn.IsZero() && time.Now().After(c.Token.ExpiresOn) {
+		return Token{}, fmt.Errorf("static token expired at %s", c.Token.ExpiresOn)
+	}
+	return c.Token, nil
+}
+
+// ChainedCredential tries each credential in order and returns the first token that could be obtained
+type ChainedCredential []TokenCredential
+
+// GetToken returns the token of the first credential that succeeds, or an error containing the errors of all credentials
+func (c ChainedCredential) GetToken(ctx context.Context, scopes []string) (Token, error) {
+	if len(c) == 0 {
+		return Token{}, fmt.Errorf("no credentials configured")
+	}
+
+	errs := make([]string, 0, len(c))
+
+	for _, cred := range c {
+		tok, err := cred.GetToken(ctx, scopes)
+		if err == nil {
+			return tok, nil
+		}
+		errs = append(errs, fmt.Sprintf("%T: %s", cred, err))
+	}
+
+	return Token{}, fmt.Errorf("no credential could obtain a token:\n%s", strings.Join(errs, "\n"))
+}
+
+// NewEnvironmentCredential returns a ChainedCredential with all credentials configured in the environment, in this order:
+//
+//	AZURE_FEDERATED_TOKEN_FILE and AZURE_CLIENT_ID: FederatedCredential
+//	AZURE_CLIENT_CERTIFICATE_PATH (and AZURE_CLIENT_CERTIFICATE_PASSWORD for PFX files) and AZURE_CLIENT_ID: ClientCertificateCredential
+//	AZURE_CLIENT_SECRET and AZURE_CLIENT_ID: ClientSecretCredential
+//	always: ManagedIdentity, with AZURE_CLIENT_ID as user-assigned identity if set
+func NewEnvironmentCredential(tenantDomain string) (ChainedCredential, error) {
+	chain := ChainedCredential{}
+	clientID := os.Getenv("AZURE_CLIENT_ID")
+
+	if tokenFile := os.Getenv(FederatedTokenFileEnv); tokenFile != "" && clientID != "" {
+		chain = append(chain, FederatedCredential{TenantDomain: tenantDomain, ClientID: clientID, TokenFile: tokenFile})
+	}
+
+	if certPath := os.Getenv("AZURE_CLIENT_CERTIFICATE_PATH"); certPath != "" && clientID != "" {
+		cc, err := LoadClientCertificate(certPath, os.Getenv("AZURE_CLIENT_CERTIFICATE_PASSWORD"))
+		if err != nil {
+			return nil, err
+		}
+
+		chain = append(chain, ClientCertificateCredential{TenantDomain: tenantDomain, ClientID: clientID, Certificate: cc})
+	}
+
+	if secret := os.Getenv("AZURE_CLIENT_SECRET"); secret != "" && clientID != "" {
+		chain = append(chain, ClientSecretCredential{TenantDomain: tenantDomain, ClientID: clientID, ClientSecret: secret})
+	}
+
+	chain = append(chain, &ManagedIdentity{ClientID: clientID})
+
+	return chain, nil
+}
+
+// tokenURL returns the v2.0 token endpoint of the tenant
+func tokenURL(cloud Cloud, tenantDomain string) string {
+	return cloud.orDefault().LoginURL + tenantDomain + "/oauth2/v2.0/token"
+}
+
+// requestToken requests a token with the client credentials grant from the v2.0 token endpoint of the tenant
+func requestToken(ctx context.Context, cloud Cloud, tenantDomain string, parameters url.Values, scopes []string) (Token, error) {
+	parameters.Set("grant_type", "client_credentials")
+	parameters.Set("scope", strings.Join(scopes, " "))
+
+	return postTokenRequest(ctx, tokenURL(cloud, tenantDomain), parameters)
+}
+
+// postTokenRequest sends parameters to the token endpoint authAuthenticatorURL and returns the token of the response
+func postTokenRequest(ctx context.Context, authAuthenticatorURL string, parameters url.Values) (Token, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", authAuthenticatorURL, strings.NewReader(parameters.Encode()))
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Token{}, fmt.Errorf("Error in POSTing the token request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Token{}, fmt.Errorf("error in reading the response body: %s", err)
+	}
+
+	if resp.StatusCode != 200 {
+		return Token{}, fmt.Errorf("error while calling auth endpoint %s: %s", authAuthenticatorURL, string(bodyBytes))
+	}
+
+	tr := tokenResponse{}
+
+	err = json.Unmarshal(bodyBytes, &tr)
+	if err != nil {
+		return Token{}, fmt.Errorf("Error getting the Access token: %s", err)
+	}
+
+	return tr.token(), nil
+}
+
+// credential returns the TokenCredential used by t: Credential if it is set, then ManagedIdentity,
+// and otherwise one built from the ClientID and the configured secret, certificate or federated token file
+func (t Tenant) credential() TokenCredential {
+	switch {
+	case t.Credential != nil:
+		return t.Credential
+	case t.ManagedIdentity != nil:
+		return t.ManagedIdentity
+	case t.FederatedTokenFile != "":
+		return FederatedCredential{TenantDomain: t.authority(), ClientID: t.ClientID, TokenFile: t.FederatedTokenFile, Cloud: t.Cloud}
+	case t.ClientCertificate != nil:
+		return ClientCertificateCredential{TenantDomain: t.authority(), ClientID: t.ClientID, Certificate: t.ClientCertificate, Cloud: t.Cloud}
+	default:
+		return ClientSecretCredential{TenantDomain: t.authority(), ClientID: t.ClientID, ClientSecret: t.ClientSecret, Cloud: t.Cloud}
+	}
+}
+
+// useCredential obtains a token for resource with cred and stores it as t.AccessToken
+func (t *Tenant) useCredential(cred TokenCredential, resource string) error {
+	tok, err := cred.GetToken(context.Background(), []string{resource + ".default"})
+	if err != nil {
+		return err
+	}
+
+	t.AccessToken = AccessToken{AccessToken: tok.AccessToken, TokenType: tok.TokenType}
+	return nil
+}
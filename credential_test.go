@@ -0,0 +1,62 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+type failingCredential struct{}
+
+func (failingCredential) GetToken(ctx context.Context, scopes []string) (Token, error) {
+	return Token{}, fmt.Errorf("no token for you")
+}
+
+func TestChainedCredential(t *testing.T) {
+	static := StaticTokenCredential{Token: Token{AccessToken: "static", TokenType: "Bearer"}}
+
+	tn := Tenant{Credential: ChainedCredential{failingCredential{}, static}}
+
+	if err := tn.GetGraphAccessToken(); err != nil {
+		t.Fatalf("Error while obtaining access token: %s", err)
+	}
+
+	if tn.AccessToken.AccessToken != "static" {
+		t.Errorf("Expected token of the second credential, got %q", tn.AccessToken.AccessToken)
+	}
+
+	expired := StaticTokenCredential{Token: Token{AccessToken: "expired", ExpiresOn: time.Now().Add(-time.Minute)}}
+
+	if _, err := (ChainedCredential{failingCredential{}, expired}).GetToken(context.Background(), nil); err == nil {
+		t.Errorf("Expected error if no credential returns a token")
+	}
+}
+
+func TestTokenResponse(t *testing.T) {
+	// managed identity endpoints send expires_on as string
+	tr := tokenResponse{}
+	if err := json.Unmarshal([]byte(`{"access_token": "a", "expires_on": "1700000000"}`), &tr); err != nil {
+		t.Fatalf("Error unmarshaling token response: %s", err)
+	}
+
+	tok := tr.token()
+	if tok.ExpiresOn.Unix() != 1700000000 || tok.TokenType != "Bearer" {
+		t.Errorf("Unexpected token %+v", tok)
+	}
+}
+
+func TestEnvironmentCredential(t *testing.T) {
+	cred, err := NewEnvironmentCredential(os.Getenv("B2C_TENANT_DOMAIN"))
+	if err != nil {
+		t.Fatalf("Error while reading credentials from environment: %s", err)
+	}
+
+	tn := Tenant{Credential: cred}
+
+	if err := tn.GetGraphAccessToken(); err != nil {
+		t.Errorf("Error while obtaining access token: %s", err)
+	}
+}
@@ -0,0 +1,155 @@
+package tenant
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DeltaRemoved is set on objects of a delta response that were removed since the last delta query.
+// Reason is "changed" if the object can still be restored, or "deleted" if it is gone for good.
+type DeltaRemoved struct {
+	Reason string `json:"reason"`
+}
+
+// UserDelta is a changed or removed user as returned by users/delta
+type UserDelta struct {
+	User
+	Removed *DeltaRemoved `json:"@removed,omitempty"`
+}
+
+// IsRemoved returns true if the user was removed from the directory since the last delta query
+func (u UserDelta) IsRemoved() bool {
+	return u.Removed != nil
+}
+
+// MemberDelta is an added or removed member of a GroupDelta
+type MemberDelta struct {
+	Type    string        `json:"@odata.type"`
+	ID      string        `json:"id"`
+	Removed *DeltaRemoved `json:"@removed,omitempty"`
+}
+
+// IsRemoved returns true if the member was removed from the group since the last delta query
+func (m MemberDelta) IsRemoved() bool {
+	return m.Removed != nil
+}
+
+// GroupDelta is a changed or removed group as returned by groups/delta, including the member changes
+type GroupDelta struct {
+	Group
+	Members []MemberDelta `json:"members@delta"`
+	Removed *DeltaRemoved `json:"@removed,omitempty"`
+}
+
+// IsRemoved returns true if the group was removed from the directory since the last delta query
+func (g GroupDelta) IsRemoved() bool {
+	return g.Removed != nil
+}
+
+// UserDeltaResponse simply contains the API response (within the 'value' tag) type for our JSON unmarshaler to put the data into
+type UserDeltaResponse struct {
+	Users      []UserDelta `json:"value"`
+	ODataNext  string      `json:"@odata.nextLink"`
+	ODataDelta string      `json:"@odata.deltaLink"`
+}
+
+// GroupDeltaResponse simply contains the API response (within the 'value' tag) type for our JSON unmarshaler to put the data into
+type GroupDeltaResponse struct {
+	Groups     []GroupDelta `json:"value"`
+	ODataNext  string       `json:"@odata.nextLink"`
+	ODataDelta string       `json:"@odata.deltaLink"`
+}
+
+// GetUsersDelta returns all users that changed since the delta query that returned deltaLink, and the deltaLink for the next call.
+// With an empty deltaLink, all users of the directory are returned.
+// The deltaLink is an opaque string that should be persisted between runs.
+func (t Tenant) GetUsersDelta(deltaLink string) ([]UserDelta, string, error) {
+	users := []UserDelta{}
+
+	next, err := t.deltaPages("/users/delta", "", deltaLink, func(page []byte) (string, string, error) {
+		udr := UserDeltaResponse{}
+		if err := json.Unmarshal(page, &udr); err != nil {
+			return "", "", err
+		}
+		users = append(users, udr.Users...)
+		return udr.ODataNext, udr.ODataDelta, nil
+	})
+	if err != nil {
+		return users, "", fmt.Errorf("error while reading user delta: %s", err)
+	}
+
+	return users, next, nil
+}
+
+// GetGroupsDelta returns all groups that changed since the delta query that returned deltaLink, and the deltaLink for the next call.
+// Membership changes are returned in the Members of each GroupDelta.
+// With an empty deltaLink, all groups of the directory are returned along with all their members.
+// The API may return a group on several pages with its members split across them, these are merged into one GroupDelta.
+func (t Tenant) GetGroupsDelta(deltaLink string) ([]GroupDelta, string, error) {
+	groups := []GroupDelta{}
+	index := map[string]int{}
+
+	next, err := t.deltaPages("/groups/delta", "$select=displayName,members", deltaLink, func(page []byte) (string, string, error) {
+		gdr := GroupDeltaResponse{}
+		if err := json.Unmarshal(page, &gdr); err != nil {
+			return "", "", err
+		}
+
+		for _, group := range gdr.Groups {
+			i, ok := index[group.ID]
+			if !ok {
+				index[group.ID] = len(groups)
+				groups = append(groups, group)
+				continue
+			}
+
+			groups[i].Members = append(groups[i].Members, group.Members...)
+			if group.DisplayName != "" {
+				groups[i].DisplayName = group.DisplayName
+			}
+			if group.Removed != nil {
+				groups[i].Removed = group.Removed
+			}
+		}
+
+		return gdr.ODataNext, gdr.ODataDelta, nil
+	})
+	if err != nil {
+		return groups, "", fmt.Errorf("error while reading group delta: %s", err)
+	}
+
+	return groups, next, nil
+}
+
+// deltaPages calls endpoint, or deltaLink if set, and follows all odata.nextLinks until the API returns the odata.deltaLink.
+// Each page is handed to decode, which returns the nextLink and deltaLink of the page.
+func (t Tenant) deltaPages(endpoint, param, deltaLink string, decode func([]byte) (string, string, error)) (string, error) {
+	var response []byte
+	var err error
+
+	if deltaLink == "" {
+		response, err = t.callNewGraphAPI(endpoint, "GET", param)
+	} else {
+		response, err = t.callNewGraphAPI(deltaLink, "odatanext", "")
+	}
+
+	for {
+		if err != nil {
+			return "", fmt.Errorf("%s\n%s", err, string(response))
+		}
+
+		nextLink, newDeltaLink, decodeErr := decode(response)
+		if decodeErr != nil {
+			return "", fmt.Errorf("error unmarshaling JSON response: %s", decodeErr)
+		}
+
+		if nextLink == "" {
+			if newDeltaLink == "" {
+				return "", fmt.Errorf("last page has neither odata.nextLink nor odata.deltaLink")
+			}
+			return newDeltaLink, nil
+		}
+
+		response, err = t.callNewGraphAPI(nextLink, "odatanext", "")
+	}
+}
@@ -0,0 +1,104 @@
+package tenant
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGroupDeltaUnmarshal(t *testing.T) {
+	page := `{
+		"value": [
+			{"id": "g1", "displayName": "Admins", "members@delta": [
+				{"@odata.type": "#microsoft.graph.user", "id": "u1"},
+				{"@odata.type": "#microsoft.graph.user", "id": "u2", "@removed": {"reason": "deleted"}}
+			]},
+			{"id": "g2", "@removed": {"reason": "changed"}}
+		],
+		"@odata.deltaLink": "https://graph.microsoft.com/beta/groups/delta?$deltatoken=abc"
+	}`
+
+	gdr := GroupDeltaResponse{}
+	if err := json.Unmarshal([]byte(page), &gdr); err != nil {
+		t.Fatalf("Error unmarshaling delta page: %s", err)
+	}
+
+	if len(gdr.Groups) != 2 || gdr.Groups[0].DisplayName != "Admins" || gdr.Groups[0].IsRemoved() || !gdr.Groups[1].IsRemoved() {
+		t.Errorf("Unexpected groups: %+v", gdr.Groups)
+	}
+
+	members := gdr.Groups[0].Members
+	if len(members) != 2 || members[0].IsRemoved() || !members[1].IsRemoved() {
+		t.Errorf("Unexpected member changes: %+v", members)
+	}
+
+	if gdr.ODataDelta == "" {
+		t.Errorf("Expected deltaLink to be set")
+	}
+}
+
+func TestGetGroupsDeltaMerge(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Write([]byte(`{"value": [
+				{"id": "g1", "displayName": "Admins", "members@delta": [{"id": "u1"}]},
+				{"id": "g2", "displayName": "Users"}
+			], "@odata.nextLink": "` + server.URL + `/beta/groups/delta?page=2"}`))
+		case "2":
+			w.Write([]byte(`{"value": [
+				{"id": "g1", "members@delta": [{"id": "u2"}]}
+			], "@odata.deltaLink": "` + server.URL + `/beta/groups/delta?page=3"}`))
+		default:
+			w.Write([]byte(`{"value": []}`))
+		}
+	}))
+	defer server.Close()
+
+	tn := Tenant{Cloud: Cloud{LoginURL: server.URL + "/", GraphURL: server.URL + "/"}}
+
+	groups, deltaLink, err := tn.GetGroupsDelta("")
+	if err != nil {
+		t.Fatalf("Error while reading group delta: %s", err)
+	}
+
+	if len(groups) != 2 || groups[0].DisplayName != "Admins" || len(groups[0].Members) != 2 || groups[0].Members[1].ID != "u2" {
+		t.Errorf("Expected the members of g1 to be merged, got %+v", groups)
+	}
+
+	if !strings.HasSuffix(deltaLink, "page=3") {
+		t.Errorf("Unexpected deltaLink %q", deltaLink)
+	}
+
+	// the page of the deltaLink has neither a nextLink nor a deltaLink
+	if _, _, err := tn.GetGroupsDelta(deltaLink); err == nil {
+		t.Errorf("Expected an error for a missing deltaLink")
+	}
+}
+
+func TestGetUsersDelta(t *testing.T) {
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
+
+	if err := tn.GetGraphAccessToken(); err != nil {
+		t.Errorf("Error while obtaining access token: %s", err)
+	}
+
+	users, deltaLink, err := tn.GetUsersDelta("")
+	if err != nil {
+		t.Fatalf("Error while reading initial user delta: %s", err)
+	}
+
+	if len(users) == 0 || deltaLink == "" {
+		t.Errorf("Expected users and a deltaLink, got %d users and deltaLink %q", len(users), deltaLink)
+	}
+
+	if _, _, err := tn.GetUsersDelta(deltaLink); err != nil {
+		t.Errorf("Error while reading user delta: %s", err)
+	}
+}
@@ -0,0 +1,233 @@
+package tenant
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// utf8BOM is stripped from policy files, the portal exports them with a byte order mark
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// settingsToken matches the {Settings:Name} tokens that have to be substituted before a policy is uploaded
+var settingsToken = regexp.MustCompile(`\{Settings:[^}]+\}`)
+
+// PolicyFile is a custom policy read from disk, with all substitutions applied
+type PolicyFile struct {
+	Path         string
+	PolicyID     string
+	BasePolicyID string
+	Content      []byte
+}
+
+// policyHeader contains the parts of a TrustFrameworkPolicy needed to order the upload
+type policyHeader struct {
+	XMLName    xml.Name `xml:"TrustFrameworkPolicy"`
+	PolicyID   string   `xml:"PolicyId,attr"`
+	BasePolicy struct {
+		PolicyID string `xml:"PolicyId"`
+	} `xml:"BasePolicy"`
+}
+
+// DeployOptions control how DeployPolicies uploads a policy set
+type DeployOptions struct {
+	// DryRun only computes the plan without uploading any policy
+	DryRun bool
+	// Substitutions replaces each key with its value in all policies, e.g. "{Settings:TenantName}" or an app ID of another environment.
+	// Any {Settings:Name} token left after the substitution is an error.
+	Substitutions map[string]string
+}
+
+// DeployPlan contains the policies in the order they are uploaded, base policies first
+type DeployPlan struct {
+	Policies []PolicyFile
+}
+
+func (p DeployPlan) String() string {
+	if len(p.Policies) == 0 {
+		return "no policies"
+	}
+
+	lines := make([]string, len(p.Policies))
+	for i, policy := range p.Policies {
+		lines[i] = fmt.Sprintf("%d. %s (%s)", i+1, policy.PolicyID, filepath.Base(policy.Path))
+		if policy.BasePolicyID != "" {
+			lines[i] += " based on " + policy.BasePolicyID
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// DeployPolicies uploads all *.xml policies in dir so that each policy is uploaded after its BasePolicy.
+// Base policies that are not part of dir have to exist in the tenant already.
+// The plan is returned with opts.DryRun as well, if an upload fails, the policies before it have been uploaded.
+func (t Tenant) DeployPolicies(dir string, opts DeployOptions) (DeployPlan, error) {
+	policies, err := LoadPolicies(dir, opts.Substitutions)
+	if err != nil {
+		return DeployPlan{}, err
+	}
+
+	sorted, err := SortPolicies(policies)
+	if err != nil {
+		return DeployPlan{}, err
+	}
+
+	plan := DeployPlan{Policies: sorted}
+
+	if opts.DryRun {
+		return plan, nil
+	}
+
+	for _, policy := range plan.Policies {
+		if err := t.UploadPolicy(policy.PolicyID, policy.Content); err != nil {
+			return plan, fmt.Errorf("error while deploying %s: %s", policy.Path, err)
+		}
+	}
+
+	return plan, nil
+}
+
+// LoadPolicies reads all *.xml policies in dir, applies the substitutions and checks that they are well-formed
+func LoadPolicies(dir string, substitutions map[string]string) ([]PolicyFile, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.xml"))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no policies found in %s", dir)
+	}
+
+	policies := []PolicyFile{}
+
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		policy, err := parsePolicy(path, content, substitutions)
+		if err != nil {
+			return nil, err
+		}
+
+		policies = append(policies, policy)
+	}
+
+	return policies, nil
+}
+
+// parsePolicy substitutes the tokens in content and reads the policy IDs
+func parsePolicy(path string, content []byte, substitutions map[string]string) (PolicyFile, error) {
+	content = bytes.TrimPrefix(content, utf8BOM)
+
+	// replace longer tokens first, so a token that is a prefix of another one doesn't break it
+	tokens := make([]string, 0, len(substitutions))
+	for token := range substitutions {
+		tokens = append(tokens, token)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return len(tokens[i]) > len(tokens[j]) })
+
+	for _, token := range tokens {
+		content = bytes.Replace(content, []byte(token), []byte(substitutions[token]), -1)
+	}
+
+	if missing := settingsToken.FindAll(content, -1); len(missing) > 0 {
+		return PolicyFile{}, fmt.Errorf("%s: no substitution for %s", path, missing[0])
+	}
+
+	if err := checkWellFormed(content); err != nil {
+		return PolicyFile{}, fmt.Errorf("%s: invalid XML: %s", path, err)
+	}
+
+	header := policyHeader{}
+	if err := xml.Unmarshal(content, &header); err != nil {
+		return PolicyFile{}, fmt.Errorf("%s: not a TrustFrameworkPolicy: %s", path, err)
+	}
+
+	if header.PolicyID == "" {
+		return PolicyFile{}, fmt.Errorf("%s: PolicyId is missing", path)
+	}
+
+	return PolicyFile{
+		Path:         path,
+		PolicyID:     header.PolicyID,
+		BasePolicyID: strings.TrimSpace(header.BasePolicy.PolicyID),
+		Content:      content,
+	}, nil
+}
+
+// checkWellFormed reads the whole document, xml.Unmarshal stops after the root element
+func checkWellFormed(content []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// SortPolicies orders the policies so that each policy comes after its BasePolicy, policies on the same level
+// are sorted by ID. Base policies that are not in policies are assumed to exist.
+func SortPolicies(policies []PolicyFile) ([]PolicyFile, error) {
+	byID := map[string]PolicyFile{}
+	children := map[string][]string{}
+
+	for _, policy := range policies {
+		if existing, ok := byID[policy.PolicyID]; ok {
+			return nil, fmt.Errorf("policy %s is defined in %s and %s", policy.PolicyID, existing.Path, policy.Path)
+		}
+		byID[policy.PolicyID] = policy
+	}
+
+	ready := []string{}
+	for _, policy := range policies {
+		if _, ok := byID[policy.BasePolicyID]; ok {
+			children[policy.BasePolicyID] = append(children[policy.BasePolicyID], policy.PolicyID)
+		} else {
+			ready = append(ready, policy.PolicyID)
+		}
+	}
+
+	sorted := []PolicyFile{}
+
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		id := ready[0]
+		ready = append(ready[1:], children[id]...)
+		sorted = append(sorted, byID[id])
+	}
+
+	if len(sorted) != len(policies) {
+		cycle := []string{}
+		for _, policy := range policies {
+			if !containsPolicy(sorted, policy.PolicyID) {
+				cycle = append(cycle, policy.PolicyID)
+			}
+		}
+		sort.Strings(cycle)
+		return nil, fmt.Errorf("circular BasePolicy references between %s", strings.Join(cycle, ", "))
+	}
+
+	return sorted, nil
+}
+
+func containsPolicy(policies []PolicyFile, policyID string) bool {
+	for _, policy := range policies {
+		if policy.PolicyID == policyID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package tenant
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDeployPoliciesDryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "b2c-tenant")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"SignUpOrSignin.xml":     `<TrustFrameworkPolicy PolicyId="B2C_1A_signup_signin" TenantId="{Settings:TenantName}"><BasePolicy><PolicyId>B2C_1A_TrustFrameworkExtensions</PolicyId></BasePolicy></TrustFrameworkPolicy>`,
+		"TrustFrameworkBase.xml": "\xef\xbb\xbf" + `<TrustFrameworkPolicy PolicyId="B2C_1A_TrustFrameworkBase" TenantId="{Settings:TenantName}"/>`,
+		"TrustFrameworkExtensions.xml": `<TrustFrameworkPolicy PolicyId="B2C_1A_TrustFrameworkExtensions"><BasePolicy><PolicyId>B2C_1A_TrustFrameworkBase</PolicyId></BasePolicy>
+			<ClaimsProviders><ClientId>{Settings:ProxyAppID}</ClientId></ClaimsProviders></TrustFrameworkPolicy>`,
+	}
+
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("Error writing policy file: %s", err)
+		}
+	}
+
+	opts := DeployOptions{
+		DryRun:        true,
+		Substitutions: map[string]string{"{Settings:TenantName}": "example.onmicrosoft.com"},
+	}
+
+	if _, err := (Tenant{}).DeployPolicies(dir, opts); err == nil || !strings.Contains(err.Error(), "{Settings:ProxyAppID}") {
+		t.Errorf("Expected error for missing substitution, got %v", err)
+	}
+
+	opts.Substitutions["{Settings:ProxyAppID}"] = "proxy-app-id"
+
+	plan, err := (Tenant{}).DeployPolicies(dir, opts)
+	if err != nil {
+		t.Fatalf("Error while planning deployment: %s", err)
+	}
+
+	order := []string{"B2C_1A_TrustFrameworkBase", "B2C_1A_TrustFrameworkExtensions", "B2C_1A_signup_signin"}
+	if len(plan.Policies) != len(order) {
+		t.Fatalf("Unexpected plan:\n%s", plan)
+	}
+
+	for i, id := range order {
+		if plan.Policies[i].PolicyID != id {
+			t.Errorf("Unexpected plan:\n%s", plan)
+			break
+		}
+	}
+
+	if !strings.Contains(string(plan.Policies[0].Content), `TenantId="example.onmicrosoft.com"`) {
+		t.Errorf("Substitution not applied: %s", plan.Policies[0].Content)
+	}
+}
+
+func TestSortPoliciesCycle(t *testing.T) {
+	policies := []PolicyFile{
+		{PolicyID: "A", BasePolicyID: "B"},
+		{PolicyID: "B", BasePolicyID: "A"},
+		{PolicyID: "C"},
+	}
+
+	if _, err := SortPolicies(policies); err == nil || !strings.Contains(err.Error(), "A, B") {
+		t.Errorf("Expected error for circular references, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+// Package exporter provides a Prometheus collector for the B2C usage reports and directory statistics.
+//
+// The authentication and MFA counts are rolling 30 day totals as returned by the upstream API,
+// use e.g. delta() or deriv() over them in Prometheus to get daily numbers.
+package exporter
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	tenant "github.com/karrieretutor/b2c-tenant"
+)
+
+const namespace = "b2c"
+
+// DefaultCacheDuration is how long scraped values are reused, the reports are only updated a few times a day
+const DefaultCacheDuration = 5 * time.Minute
+
+// DefaultRecentWindow is the window for b2c_users_created_recent
+const DefaultRecentWindow = 24 * time.Hour
+
+// Collector is a prometheus.Collector exposing the statistics of a B2C tenant.
+// Graph is only queried if the cached values are older than CacheDuration.
+type Collector struct {
+	tenant tenant.Tenant
+
+	// CacheDuration defaults to DefaultCacheDuration
+	CacheDuration time.Duration
+	// RecentWindow defaults to DefaultRecentWindow
+	RecentWindow time.Duration
+
+	authenticationCount *prometheus.Desc
+	mfaRequestCount     *prometheus.Desc
+	users               *prometheus.Desc
+	usersCreatedRecent  *prometheus.Desc
+	groups              *prometheus.Desc
+	scrapeErrors        *prometheus.Desc
+	scrapeDuration      *prometheus.Desc
+
+	mu       sync.Mutex
+	scraped  time.Time
+	values   map[*prometheus.Desc]float64
+	errors   map[string]float64
+	duration float64
+}
+
+// New returns a Collector for the tenant t, which has to be configured with credentials.
+// The collector obtains its own access tokens for each scrape.
+func New(t tenant.Tenant) *Collector {
+	return &Collector{
+		tenant:        t,
+		CacheDuration: DefaultCacheDuration,
+		RecentWindow:  DefaultRecentWindow,
+
+		authenticationCount: prometheus.NewDesc(namespace+"_authentication_count", "B2C authentications in the last 30 days.", nil, nil),
+		mfaRequestCount:     prometheus.NewDesc(namespace+"_mfa_request_count", "B2C multi-factor authentication requests in the last 30 days.", nil, nil),
+		users:               prometheus.NewDesc(namespace+"_users", "Users in the directory.", nil, nil),
+		usersCreatedRecent:  prometheus.NewDesc(namespace+"_users_created_recent", "Users created within the recent window.", nil, nil),
+		groups:              prometheus.NewDesc(namespace+"_groups", "Groups in the directory.", nil, nil),
+		scrapeErrors:        prometheus.NewDesc(namespace+"_scrape_errors_total", "Errors while reading the statistics from Graph.", []string{"statistic"}, nil),
+		scrapeDuration:      prometheus.NewDesc(namespace+"_scrape_duration_seconds", "Duration of the last scrape of Graph.", nil, nil),
+
+		values: map[*prometheus.Desc]float64{},
+		errors: map[string]float64{},
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.authenticationCount
+	ch <- c.mfaRequestCount
+	ch <- c.users
+	ch <- c.usersCreatedRecent
+	ch <- c.groups
+	ch <- c.scrapeErrors
+	ch <- c.scrapeDuration
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.scraped) > c.CacheDuration {
+		c.scrape()
+	}
+
+	for desc, value := range c.values {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value)
+	}
+
+	for statistic, count := range c.errors {
+		ch <- prometheus.MustNewConstMetric(c.scrapeErrors, prometheus.CounterValue, count, statistic)
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.scrapeDuration, prometheus.GaugeValue, c.duration)
+}
+
+// scrape reads all statistics from Graph, c.mu must be held.
+// Statistics that fail keep their previous value and increase their error counter.
+func (c *Collector) scrape() {
+	start := time.Now()
+
+	// the reports are only available in the Azure AD Graph API, the rest in Microsoft Graph, and each needs its own token
+	aadGraph := c.tenant
+	aadGraphErr := aadGraph.GetAccessToken()
+
+	msGraph := c.tenant
+	msGraphErr := msGraph.GetGraphAccessToken()
+
+	c.record("authentication_count", c.authenticationCount, aadGraphErr, aadGraph.GetB2CAuthenticationCount)
+	c.record("mfa_request_count", c.mfaRequestCount, aadGraphErr, aadGraph.GetB2CMFARequestCount)
+
+	c.record("users", c.users, msGraphErr, func() (float64, error) {
+		count, err := msGraph.CountUsers(time.Time{})
+		return float64(count), err
+	})
+	c.record("users_created_recent", c.usersCreatedRecent, msGraphErr, func() (float64, error) {
+		count, err := msGraph.CountUsers(time.Now().Add(-c.RecentWindow))
+		return float64(count), err
+	})
+	c.record("groups", c.groups, msGraphErr, func() (float64, error) {
+		count, err := msGraph.CountGroups()
+		return float64(count), err
+	})
+
+	c.duration = time.Since(start).Seconds()
+	c.scraped = time.Now()
+}
+
+// record stores the result of get as value of desc, or counts an error for statistic.
+// A panic in get is counted as error as well, so a single statistic can't break the scrape.
+func (c *Collector) record(statistic string, desc *prometheus.Desc, tokenErr error, get func() (float64, error)) {
+	// make sure every statistic has an error counter, even if it never fails
+	c.errors[statistic] += 0
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("panic reading %s: %v", statistic, r)
+			c.errors[statistic]++
+		}
+	}()
+
+	if tokenErr != nil {
+		log.Printf("error obtaining access token for %s: %s", statistic, tokenErr)
+		c.errors[statistic]++
+		return
+	}
+
+	value, err := get()
+	if err != nil {
+		log.Printf("error reading %s: %s", statistic, err)
+		c.errors[statistic]++
+		return
+	}
+
+	c.values[desc] = value
+}
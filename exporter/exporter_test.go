@@ -0,0 +1,106 @@
+package exporter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	tenant "github.com/karrieretutor/b2c-tenant"
+)
+
+func TestCollector(t *testing.T) {
+	calls := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch {
+		case strings.Contains(r.URL.Path, "b2cAuthenticationCount"):
+			w.Write([]byte(`{"value": [{"AuthenticationCount": 42}]}`))
+		case strings.Contains(r.URL.Path, "b2cMfaRequestCount"):
+			http.Error(w, "not available", http.StatusForbidden)
+		case r.Header.Get("ConsistencyLevel") != "eventual":
+			http.Error(w, "count requires ConsistencyLevel", http.StatusBadRequest)
+		case r.URL.Path == "/beta/users/$count" && r.URL.Query().Get("$filter") != "":
+			w.Write([]byte("1"))
+		case r.URL.Path == "/beta/users/$count":
+			w.Write([]byte("2"))
+		case r.URL.Path == "/beta/groups/$count":
+			w.Write([]byte("1"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tn := tenant.Tenant{
+		TenantDomain: "example.onmicrosoft.com",
+		Cloud:        tenant.Cloud{LoginURL: server.URL + "/", AADGraphURL: server.URL + "/", GraphURL: server.URL + "/"},
+		Credential:   tenant.StaticTokenCredential{Token: tenant.Token{AccessToken: "token", TokenType: "Bearer"}},
+	}
+
+	c := New(tn)
+	c.CacheDuration = time.Hour
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+
+	expected := `
+# HELP b2c_authentication_count B2C authentications in the last 30 days.
+# TYPE b2c_authentication_count gauge
+b2c_authentication_count 42
+# HELP b2c_groups Groups in the directory.
+# TYPE b2c_groups gauge
+b2c_groups 1
+# HELP b2c_scrape_errors_total Errors while reading the statistics from Graph.
+# TYPE b2c_scrape_errors_total counter
+b2c_scrape_errors_total{statistic="authentication_count"} 0
+b2c_scrape_errors_total{statistic="groups"} 0
+b2c_scrape_errors_total{statistic="mfa_request_count"} 1
+b2c_scrape_errors_total{statistic="users"} 0
+b2c_scrape_errors_total{statistic="users_created_recent"} 0
+# HELP b2c_users Users in the directory.
+# TYPE b2c_users gauge
+b2c_users 2
+# HELP b2c_users_created_recent Users created within the recent window.
+# TYPE b2c_users_created_recent gauge
+b2c_users_created_recent 1
+`
+
+	names := []string{"b2c_authentication_count", "b2c_mfa_request_count", "b2c_users", "b2c_users_created_recent", "b2c_groups", "b2c_scrape_errors_total"}
+
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), names...); err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+
+	// the second scrape is served from the cache
+	scrapeCalls := calls
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), names...); err != nil {
+		t.Errorf("Unexpected metrics: %s", err)
+	}
+
+	if calls != scrapeCalls {
+		t.Errorf("Expected cached values, Graph was called %d more times", calls-scrapeCalls)
+	}
+}
+
+func TestRecordPanic(t *testing.T) {
+	c := New(tenant.Tenant{})
+
+	c.record("users", c.users, nil, func() (float64, error) {
+		var counts []float64
+		return counts[0], nil
+	})
+
+	if c.errors["users"] != 1 {
+		t.Errorf("Expected the panic to be counted as error, got %v", c.errors["users"])
+	}
+
+	if _, ok := c.values[c.users]; ok {
+		t.Errorf("Expected no value after a panic")
+	}
+}
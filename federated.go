@@ -0,0 +1,27 @@
+package tenant
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// FederatedTokenFileEnv is the environment variable that contains the path of the projected service account token
+// when running with workload identity federation in Kubernetes
+const FederatedTokenFileEnv = "AZURE_FEDERATED_TOKEN_FILE"
+
+// readFederatedToken reads the federated identity token from path.
+// The file is rotated by the platform, so it has to be read again for every token request.
+func readFederatedToken(path string) (string, error) {
+	tokenBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading federated token file: %s", err)
+	}
+
+	token := strings.TrimSpace(string(tokenBytes))
+	if token == "" {
+		return "", fmt.Errorf("federated token file %s is empty", path)
+	}
+
+	return token, nil
+}
@@ -0,0 +1,81 @@
+package tenant
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetAccessTokenFederated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "b2c-tenant")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tokenFile := filepath.Join(dir, "token")
+
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/example.onmicrosoft.com/oauth2/v2.0/token" {
+			http.NotFound(w, r)
+			return
+		}
+		r.ParseForm()
+		form = r.PostForm
+		w.Write([]byte(`{"access_token": "token", "token_type": "Bearer", "expires_in": 3600}`))
+	}))
+	defer server.Close()
+
+	tn := Tenant{
+		ClientID:           "client-id",
+		ClientSecret:       "secret",
+		TenantDomain:       "example.onmicrosoft.com",
+		FederatedTokenFile: tokenFile,
+		Cloud:              Cloud{LoginURL: server.URL + "/", AADGraphURL: server.URL + "/aad/", GraphURL: server.URL + "/"},
+	}
+
+	if err := tn.GetAccessToken(); err == nil {
+		t.Errorf("Expected error for missing token file")
+	}
+
+	// the file has to be read again on every call, as the platform rotates it
+	for _, token := range []string{"first-token", "second-token"} {
+		if err := ioutil.WriteFile(tokenFile, []byte(token+"\n"), 0600); err != nil {
+			t.Fatalf("Error writing token file: %s", err)
+		}
+
+		if err := tn.GetAccessToken(); err != nil {
+			t.Fatalf("Error while obtaining access token: %s", err)
+		}
+
+		if form.Get("client_assertion") != token || form.Get("client_assertion_type") != clientAssertionType || form.Get("scope") != server.URL+"/aad/.default" {
+			t.Errorf("Unexpected token request %v", form)
+		}
+
+		if form.Get("client_secret") != "" {
+			t.Errorf("Expected client secret not to be sent")
+		}
+	}
+
+	// the v1 endpoint doesn't accept federated tokens, so they are never part of its client credentials
+	parameters, err := tn.clientCredentials("")
+	if err != nil || parameters.Get("client_assertion") != "" || parameters.Get("client_secret") != "secret" {
+		t.Errorf("Unexpected v1 client credentials %v: %v", parameters, err)
+	}
+}
+
+func TestGetGraphAccessTokenFederated(t *testing.T) {
+	tn := Tenant{}
+	tn.ClientID = os.Getenv("AZURE_CLIENT_ID")
+	tn.FederatedTokenFile = os.Getenv(FederatedTokenFileEnv)
+	tn.TenantDomain = os.Getenv("B2C_TENANT_DOMAIN")
+
+	if err := tn.GetGraphAccessToken(); err != nil {
+		t.Errorf("Error while obtaining access token: %s", err)
+	}
+}
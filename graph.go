@@ -0,0 +1,90 @@
+package tenant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// listPages requests a Microsoft Graph collection and passes each page to decode, which returns the nextLink
+func (t Tenant) listPages(endpoint string, param string, decode func([]byte) (string, error)) error {
+	response, err := t.callNewGraphAPI(endpoint, "GET", param)
+
+	for {
+		if err != nil {
+			return fmt.Errorf("error while reading %s: %s", endpoint, err)
+		}
+
+		nextLink, decodeErr := decode(response)
+		if decodeErr != nil {
+			return decodeErr
+		}
+
+		if nextLink == "" {
+			return nil
+		}
+
+		response, err = t.callNewGraphAPI(nextLink, "odatanext", "")
+	}
+}
+
+// getObject reads a Microsoft Graph object into v, name is used in errors
+func (t Tenant) getObject(endpoint string, param string, name string, v interface{}) error {
+	if name == "" {
+		return errors.New("ID is empty")
+	}
+
+	response, err := t.callNewGraphAPI(endpoint, "GET", param)
+	if err != nil {
+		return fmt.Errorf("error while reading %s: %s", name, err)
+	}
+
+	if err := json.Unmarshal(response, v); err != nil {
+		return fmt.Errorf("error unmarshaling JSON response: %s", err)
+	}
+
+	return nil
+}
+
+// postObject creates a Microsoft Graph object from payload and reads the created object into v
+func (t Tenant) postObject(endpoint string, payload interface{}, v interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	response, err := t.callNewGraphAPI(endpoint, "POST", string(body))
+	if err != nil {
+		return fmt.Errorf("error while creating %s: %s\n%s", endpoint, err, string(response))
+	}
+
+	if err := json.Unmarshal(response, v); err != nil {
+		return fmt.Errorf("error unmarshaling JSON response: %s", err)
+	}
+
+	return nil
+}
+
+// sendObject sends a request with payload, which may be nil, and ignores the response.
+// action and name describe the request in errors, e.g. "deleting" and the ID of the object.
+func (t Tenant) sendObject(endpoint string, method string, action string, name string, payload interface{}) error {
+	if name == "" {
+		return errors.New("ID is empty")
+	}
+
+	body := ""
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = string(encoded)
+	}
+
+	response, err := t.callNewGraphAPI(endpoint, method, body)
+	if err != nil {
+		return fmt.Errorf("error while %s %s: %s\n%s", action, name, err, string(response))
+	}
+
+	return nil
+}
@@ -2,9 +2,11 @@ package tenant
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
+	"time"
 )
 
 // GroupResponse simply contains the API response (within the 'value' tag) type for our JSON unmarshaler to put the data into
@@ -23,28 +25,86 @@ type GroupMemberResponse struct {
 type Group struct {
 	ObjectID    string `json:"objectId"`
 	DisplayName string `json:"displayName"`
+	ID          string `json:"id"`
+}
+
+// GroupCache is a TTL cache of group objectId -> Group on top of the Cache interface.
+// A single GroupCache can be shared by several Tenant values, all methods are safe for concurrent use.
+// A nil *GroupCache is valid and caches nothing.
+//
+// Deprecated: set Tenant.Cache instead, it caches users and memberships as well. A GroupCache is only
+// used by Tenant if Tenant.Cache is nil.
+type GroupCache struct {
+	ttl   time.Duration
+	cache Cache
+}
+
+// groupCacheSize is the maximum number of groups held by a GroupCache
+const groupCacheSize = 10000
+
+// NewGroupCache returns an empty GroupCache whose entries expire after ttl
+func NewGroupCache(ttl time.Duration) *GroupCache {
+	return &GroupCache{
+		ttl:   ttl,
+		cache: NewLRUCache(groupCacheSize),
+	}
+}
+
+// Get returns the cached group with the given objectId, if it exists and has not expired yet
+func (c *GroupCache) Get(objectID string) (Group, bool) {
+	if c == nil {
+		return Group{}, false
+	}
+
+	value, ok := c.cache.Get(groupCacheKey + objectID)
+	if !ok {
+		return Group{}, false
+	}
+
+	group, ok := value.(Group)
+	return group, ok
+}
+
+// Set stores the group in the cache, replacing any previous entry with the same objectId
+func (c *GroupCache) Set(group Group) {
+	if c == nil || group.ObjectID == "" {
+		return
+	}
+
+	c.cache.Set(groupCacheKey+group.ObjectID, group, c.ttl)
 }
 
 // GetGroup returns object of group in the B2C directory
 func (t Tenant) GetGroup(GroupObjectID string) (Group, error) {
+	if t.Cache == nil && t.GroupCache != nil {
+		t.Cache = t.GroupCache.cache
+		t.CacheTTLs.Group = t.GroupCache.ttl
+	}
 
-	parameter := "{\"securityEnabledOnly\": false}"
+	cacheKey := groupCacheKey + GroupObjectID
 
-	ar, err := t.callGraphAPI("/groups/"+GroupObjectID, "1.6", "GET", parameter)
-	if err != nil {
-		msg := "Error in calling API: " + err.Error()
-		log.Println(msg)
+	if cached, ok, err := t.cacheGet(cacheKey); ok {
+		if err != nil {
+			return Group{}, fmt.Errorf("error while reading group %s: %s", GroupObjectID, err)
+		}
+		return cached.(Group), nil
 	}
 
-	fmt.Print(string(ar))
+	ar, err := t.callGraphAPI("/groups/"+GroupObjectID, "1.6", "GET", "")
+	if err != nil {
+		t.cacheSetError(cacheKey, err)
+		return Group{}, fmt.Errorf("error while reading group %s: %s", GroupObjectID, err)
+	}
 
 	group := Group{}
 
 	err = json.Unmarshal(ar, &group)
 	if err != nil {
-		fmt.Println(err)
+		return Group{}, fmt.Errorf("error unmarshaling JSON response: %s", err)
 	}
 
+	t.cacheSet(cacheKey, group, t.cacheTTLs().Group)
+
 	return group, nil
 }
 
@@ -54,7 +114,7 @@ func (t Tenant) GetGroupMembers(objectID string) ([]User, error) {
 	if err != nil {
 		msg := "Error in calling API: " + err.Error()
 		log.Println(msg)
-		return []User{}, fmt.Errorf(msg)
+		return []User{}, errors.New(msg)
 	}
 
 	gmr := GroupMemberResponse{}
@@ -64,7 +124,27 @@ func (t Tenant) GetGroupMembers(objectID string) ([]User, error) {
 		fmt.Println(err)
 	}
 
-	return gmr.GroupMembers, nil
+	members := gmr.GroupMembers
+
+	// as long as we get a new odata.nextLink, continue querying the API
+	for gmr.ODataNext != "" {
+		ar, err = t.callNewGraphAPI(gmr.ODataNext, "odatanext", "")
+		if err != nil {
+			return members, fmt.Errorf("error while reading members of group %s: %s", objectID, err)
+		}
+
+		// empty gmr before each new Unmarshal to make sure ODataNext is cleared before next run
+		gmr = GroupMemberResponse{}
+
+		err = json.Unmarshal(ar, &gmr)
+		if err != nil {
+			return members, fmt.Errorf("error unmarshaling JSON response: %s", err)
+		}
+
+		members = append(members, gmr.GroupMembers...)
+	}
+
+	return members, nil
 }
 
 // GetGroups returns a list of groups in the B2C directory
@@ -95,7 +175,7 @@ func (t Tenant) AddGroupMember(aadGroup, userEmail string) error {
 		return fmt.Errorf("no user email specified")
 	}
 
-	encodedFilter := url.QueryEscape("otherMails/any(x:x eq '" + userEmail + "')")
+	encodedFilter := url.QueryEscape("otherMails/any(x:x eq " + odataString(userEmail) + ")")
 
 	response, err := t.callGraphAPI("/users", "1.6", "GET", "$filter="+encodedFilter)
 	if err != nil {
@@ -114,12 +194,13 @@ func (t Tenant) AddGroupMember(aadGroup, userEmail string) error {
 	}
 
 	for _, user := range ur.Users {
-		parameter := "{\"url\": \"https://graph.windows.net/" + t.TenantDomain + "/directoryObjects/" + user.ObjectID + "\"}"
+		parameter := "{\"url\": \"" + t.cloud().AADGraphURL + t.TenantDomain + "/directoryObjects/" + user.ObjectID + "\"}"
 
 		response, err = t.callGraphAPI("/groups/"+aadGroup+"/$links/members", "1.6", "POST", parameter)
 		if err != nil {
 			return fmt.Errorf("error while adding user: %s\n%s", err, string(response))
 		}
+		t.cacheDelete(memberGroupsCacheKey + user.ObjectID)
 		log.Printf("added user %s to group %s", user.ObjectID, aadGroup)
 	}
 
@@ -136,7 +217,7 @@ func (t Tenant) DeleteGroupMember(aadGroup, userEmail string) error {
 		return fmt.Errorf("no user email specified")
 	}
 
-	encodedFilter := url.QueryEscape("otherMails/any(x:x eq '" + userEmail + "')")
+	encodedFilter := url.QueryEscape("otherMails/any(x:x eq " + odataString(userEmail) + ")")
 
 	response, err := t.callGraphAPI("/users", "1.6", "GET", "$filter="+encodedFilter)
 	if err != nil {
@@ -159,8 +240,53 @@ func (t Tenant) DeleteGroupMember(aadGroup, userEmail string) error {
 		if err != nil {
 			return fmt.Errorf("error while deleting user: %s\n%s", err, string(response))
 		}
+		t.cacheDelete(memberGroupsCacheKey + user.ObjectID)
 		log.Printf("deleted user %s from group %s", user.ObjectID, aadGroup)
 	}
 
 	return nil
 }
+
+// AddGroupMemberByID adds the directory object with the given objectId to the specified AAD group using the Microsoft Graph API
+func (t Tenant) AddGroupMemberByID(aadGroup, objectID string) error {
+	if aadGroup == "" {
+		return fmt.Errorf("no AAD group specified")
+	}
+
+	if objectID == "" {
+		return fmt.Errorf("no object ID specified")
+	}
+
+	parameter := "{\"@odata.id\": \"" + t.graphURL() + "/directoryObjects/" + objectID + "\"}"
+
+	response, err := t.callNewGraphAPI("/groups/"+aadGroup+"/members/$ref", "POST", parameter)
+	if err != nil {
+		return fmt.Errorf("error while adding member: %s\n%s", err, string(response))
+	}
+
+	t.cacheDelete(memberGroupsCacheKey + objectID)
+	log.Printf("added member %s to group %s", objectID, aadGroup)
+
+	return nil
+}
+
+// DeleteGroupMemberByID removes the directory object with the given objectId from the specified AAD group using the Microsoft Graph API
+func (t Tenant) DeleteGroupMemberByID(aadGroup, objectID string) error {
+	if aadGroup == "" {
+		return fmt.Errorf("no AAD group specified")
+	}
+
+	if objectID == "" {
+		return fmt.Errorf("no object ID specified")
+	}
+
+	response, err := t.callNewGraphAPI("/groups/"+aadGroup+"/members/"+objectID+"/$ref", "DELETE", "")
+	if err != nil {
+		return fmt.Errorf("error while deleting member: %s\n%s", err, string(response))
+	}
+
+	t.cacheDelete(memberGroupsCacheKey + objectID)
+	log.Printf("deleted member %s from group %s", objectID, aadGroup)
+
+	return nil
+}
@@ -4,13 +4,14 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestGetGroupMembers(t *testing.T) {
-	tn := Tenant{}
-	tn.ClientID = os.Getenv("B2C_CLIENT_ID")
-	tn.ClientSecret = os.Getenv("B2C_CLIENT_SECRET")
-	tn.TenantDomain = os.Getenv("B2C_TENANT_DOMAIN")
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
 
 	if err := tn.GetGraphAccessToken(); err != nil {
 		t.Errorf("Error while obtaining access token: %s", err)
@@ -32,10 +33,10 @@ func TestGetGroupMembers(t *testing.T) {
 }
 
 func TestAddGroupMember(t *testing.T) {
-	tn := Tenant{}
-	tn.ClientID = os.Getenv("B2C_CLIENT_ID")
-	tn.ClientSecret = os.Getenv("B2C_CLIENT_SECRET")
-	tn.TenantDomain = os.Getenv("B2C_TENANT_DOMAIN")
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
 
 	if err := tn.GetAccessToken(); err != nil {
 		t.Errorf("Error while obtaining access token: %s", err)
@@ -50,10 +51,10 @@ func TestAddGroupMember(t *testing.T) {
 }
 
 func TestDeleteGroupMember(t *testing.T) {
-	tn := Tenant{}
-	tn.ClientID = os.Getenv("B2C_CLIENT_ID")
-	tn.ClientSecret = os.Getenv("B2C_CLIENT_SECRET")
-	tn.TenantDomain = os.Getenv("B2C_TENANT_DOMAIN")
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
 
 	if err := tn.GetAccessToken(); err != nil {
 		t.Errorf("Error while obtaining access token: %s", err)
@@ -66,3 +67,30 @@ func TestDeleteGroupMember(t *testing.T) {
 		t.Errorf("Error while deleting user %q from group %q: %s", userEmail, aadGroup, err)
 	}
 }
+
+func TestGroupCache(t *testing.T) {
+	gc := NewGroupCache(50 * time.Millisecond)
+
+	gc.Set(Group{ObjectID: "1", DisplayName: "Admins"})
+
+	if group, ok := gc.Get("1"); !ok || group.DisplayName != "Admins" {
+		t.Errorf("Expected cached group %q, got %q (found: %t)", "Admins", group.DisplayName, ok)
+	}
+
+	if _, ok := gc.Get("2"); ok {
+		t.Errorf("Expected group 2 not to be cached")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if _, ok := gc.Get("1"); ok {
+		t.Errorf("Expected group 1 to be expired")
+	}
+
+	// a nil cache must be usable and never return anything
+	var nilCache *GroupCache
+	nilCache.Set(Group{ObjectID: "1"})
+	if _, ok := nilCache.Get("1"); ok {
+		t.Errorf("Expected nil cache to be empty")
+	}
+}
@@ -0,0 +1,203 @@
+package tenant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// OData types of identity providers
+const (
+	ODataSocialIdentityProvider        = "#microsoft.graph.socialIdentityProvider"
+	ODataAppleIdentityProvider         = "#microsoft.graph.appleManagedIdentityProvider"
+	ODataOpenIDConnectIdentityProvider = "#microsoft.graph.openIdConnectIdentityProvider"
+)
+
+// Built-in social identity provider types
+const (
+	IdentityProviderAmazon    = "Amazon"
+	IdentityProviderFacebook  = "Facebook"
+	IdentityProviderGitHub    = "GitHub"
+	IdentityProviderGoogle    = "Google"
+	IdentityProviderLinkedIn  = "LinkedIn"
+	IdentityProviderMicrosoft = "MicrosoftAccount"
+	IdentityProviderTwitter   = "Twitter"
+)
+
+// IdentityProviderConfig is implemented by the typed identity provider configurations
+type IdentityProviderConfig interface {
+	odataType() string
+}
+
+// SocialIdentityProvider is a built-in social identity provider, e.g. Google or Facebook
+type SocialIdentityProvider struct {
+	IdentityProviderBase
+	// IdentityProviderType is one of the IdentityProvider constants, it can't be changed
+	IdentityProviderType string `json:"identityProviderType,omitempty"`
+	ClientID             string `json:"clientId,omitempty"`
+	// ClientSecret is write-only, the API returns "*****"
+	ClientSecret string `json:"clientSecret,omitempty"`
+}
+
+func (p SocialIdentityProvider) odataType() string { return ODataSocialIdentityProvider }
+
+// AppleIdentityProvider is the Sign in with Apple identity provider
+type AppleIdentityProvider struct {
+	IdentityProviderBase
+	DeveloperID string `json:"developerId,omitempty"`
+	ServiceID   string `json:"serviceId,omitempty"`
+	KeyID       string `json:"keyId,omitempty"`
+	// CertificateData is the private key (.p8) downloaded from Apple, it is write-only
+	CertificateData string `json:"certificateData,omitempty"`
+}
+
+func (p AppleIdentityProvider) odataType() string { return ODataAppleIdentityProvider }
+
+// ClaimsMapping maps the claims of an OpenID Connect provider to the user attributes
+type ClaimsMapping struct {
+	UserID      string `json:"userId"`
+	GivenName   string `json:"givenName,omitempty"`
+	Surname     string `json:"surname,omitempty"`
+	Email       string `json:"email,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+}
+
+// OpenIDConnectIdentityProvider is a generic OpenID Connect identity provider, e.g. a corporate Azure AD tenant
+type OpenIDConnectIdentityProvider struct {
+	IdentityProviderBase
+	ClientID string `json:"clientId,omitempty"`
+	// ClientSecret is write-only, the API returns "*****"
+	ClientSecret  string         `json:"clientSecret,omitempty"`
+	MetadataURL   string         `json:"metadataUrl,omitempty"`
+	DomainHint    string         `json:"domainHint,omitempty"`
+	Scope         string         `json:"scope,omitempty"`
+	ResponseMode  string         `json:"responseMode,omitempty"`
+	ResponseType  string         `json:"responseType,omitempty"`
+	ClaimsMapping *ClaimsMapping `json:"claimsMapping,omitempty"`
+}
+
+func (p OpenIDConnectIdentityProvider) odataType() string { return ODataOpenIDConnectIdentityProvider }
+
+// IdentityProvider is an identity provider of any type as returned by the API.
+// Decode it into the configuration type matching ODataType.
+type IdentityProvider struct {
+	IdentityProviderBase
+	Raw json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON keeps the raw JSON for Decode
+func (p *IdentityProvider) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &p.IdentityProviderBase); err != nil {
+		return err
+	}
+	p.Raw = append(json.RawMessage{}, data...)
+	return nil
+}
+
+// Decode unmarshals the identity provider into v, e.g. a *SocialIdentityProvider
+func (p IdentityProvider) Decode(v IdentityProviderConfig) error {
+	if v.odataType() != p.ODataType {
+		return fmt.Errorf("identity provider %s is of type %s, not %s", p.ID, p.ODataType, v.odataType())
+	}
+	return json.Unmarshal(p.Raw, v)
+}
+
+// ListIdentityProviders returns all identity providers of the tenant
+func (t Tenant) ListIdentityProviders() ([]IdentityProvider, error) {
+	providers := []IdentityProvider{}
+	err := t.listPages("/identity/identityProviders", "", func(response []byte) (string, error) {
+		page := struct {
+			Value     []IdentityProvider `json:"value"`
+			ODataNext string             `json:"@odata.nextLink"`
+		}{}
+		if err := json.Unmarshal(response, &page); err != nil {
+			return "", fmt.Errorf("error unmarshaling JSON response: %s", err)
+		}
+		providers = append(providers, page.Value...)
+		return page.ODataNext, nil
+	})
+	return providers, err
+}
+
+// GetIdentityProvider returns the identity provider with the given ID, e.g. Google-OAUTH
+func (t Tenant) GetIdentityProvider(identityProviderID string) (IdentityProvider, error) {
+	provider := IdentityProvider{}
+	err := t.getObject(identityProviderPath(identityProviderID), "", identityProviderID, &provider)
+	return provider, err
+}
+
+// CreateIdentityProvider creates an identity provider from one of the configuration types, the ID is assigned by the API
+func (t Tenant) CreateIdentityProvider(config IdentityProviderConfig) (IdentityProvider, error) {
+	created := IdentityProvider{}
+
+	payload, err := withODataType(config)
+	if err != nil {
+		return created, err
+	}
+
+	err = t.postObject("/identity/identityProviders", payload, &created)
+	return created, err
+}
+
+// UpdateIdentityProvider changes the non-empty properties of config on the identity provider
+func (t Tenant) UpdateIdentityProvider(identityProviderID string, config IdentityProviderConfig) error {
+	payload, err := withODataType(config)
+	if err != nil {
+		return err
+	}
+
+	// the ID is part of the URL and can't be changed, an empty display name would be rejected
+	delete(payload, "id")
+	if payload["displayName"] == "" {
+		delete(payload, "displayName")
+	}
+
+	return t.sendObject(identityProviderPath(identityProviderID), "PATCH", "updating", identityProviderID, payload)
+}
+
+// RotateIdentityProviderSecret replaces the client secret of a social or OpenID Connect identity provider
+func (t Tenant) RotateIdentityProviderSecret(identityProviderID string, clientSecret string) error {
+	if clientSecret == "" {
+		return errors.New("client secret is empty")
+	}
+
+	provider, err := t.GetIdentityProvider(identityProviderID)
+	if err != nil {
+		return err
+	}
+
+	switch provider.ODataType {
+	case ODataSocialIdentityProvider, ODataOpenIDConnectIdentityProvider:
+	default:
+		return fmt.Errorf("identity provider %s of type %s has no client secret", identityProviderID, provider.ODataType)
+	}
+
+	payload := map[string]string{"@odata.type": provider.ODataType, "clientSecret": clientSecret}
+	return t.sendObject(identityProviderPath(identityProviderID), "PATCH", "rotating the secret of", identityProviderID, payload)
+}
+
+// DeleteIdentityProvider deletes the identity provider, it has to be removed from all user flows first
+func (t Tenant) DeleteIdentityProvider(identityProviderID string) error {
+	return t.sendObject(identityProviderPath(identityProviderID), "DELETE", "deleting", identityProviderID, nil)
+}
+
+// withODataType returns the JSON object of config with the @odata.type the API needs to tell the types apart
+func withODataType(config IdentityProviderConfig) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := map[string]interface{}{}
+	if err := json.Unmarshal(encoded, &payload); err != nil {
+		return nil, err
+	}
+
+	payload["@odata.type"] = config.odataType()
+	return payload, nil
+}
+
+func identityProviderPath(identityProviderID string) string {
+	return "/identity/identityProviders/" + url.PathEscape(identityProviderID)
+}
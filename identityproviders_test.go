@@ -0,0 +1,62 @@
+package tenant
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIdentityProviderDecode(t *testing.T) {
+	page := `{"value": [
+		{"@odata.type": "#microsoft.graph.socialIdentityProvider", "id": "Google-OAUTH", "displayName": "Google", "identityProviderType": "Google", "clientId": "google-client", "clientSecret": "*****"},
+		{"@odata.type": "#microsoft.graph.openIdConnectIdentityProvider", "id": "OIDC-corp", "displayName": "Corp", "metadataUrl": "https://login.example.com/.well-known/openid-configuration", "claimsMapping": {"userId": "sub"}}
+	]}`
+
+	providers := struct {
+		Value []IdentityProvider `json:"value"`
+	}{}
+	if err := json.Unmarshal([]byte(page), &providers); err != nil {
+		t.Fatalf("Error unmarshaling identity providers: %s", err)
+	}
+
+	google := SocialIdentityProvider{}
+	if err := providers.Value[0].Decode(&google); err != nil || google.ClientID != "google-client" || google.IdentityProviderType != IdentityProviderGoogle {
+		t.Errorf("Unexpected social identity provider %+v: %v", google, err)
+	}
+
+	oidc := OpenIDConnectIdentityProvider{}
+	if err := providers.Value[1].Decode(&oidc); err != nil || oidc.ClaimsMapping == nil || oidc.ClaimsMapping.UserID != "sub" {
+		t.Errorf("Unexpected OpenID Connect identity provider %+v: %v", oidc, err)
+	}
+
+	if err := providers.Value[1].Decode(&SocialIdentityProvider{}); err == nil {
+		t.Errorf("Expected error decoding an OpenID Connect provider as social provider")
+	}
+}
+
+func TestRotateIdentityProviderSecret(t *testing.T) {
+	var patch map[string]string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PATCH" {
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &patch)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(`{"@odata.type": "#microsoft.graph.socialIdentityProvider", "id": "Facebook-OAUTH", "displayName": "Facebook"}`))
+	}))
+	defer server.Close()
+
+	tn := Tenant{Cloud: Cloud{LoginURL: server.URL + "/", GraphURL: server.URL + "/"}}
+
+	if err := tn.RotateIdentityProviderSecret("Facebook-OAUTH", "new-secret"); err != nil {
+		t.Fatalf("Error while rotating secret: %s", err)
+	}
+
+	if patch["@odata.type"] != ODataSocialIdentityProvider || patch["clientSecret"] != "new-secret" || len(patch) != 2 {
+		t.Errorf("Unexpected update %v", patch)
+	}
+}
@@ -0,0 +1,273 @@
+package tenant
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Key uses of policy keys
+const (
+	KeyUseSignature  = "sig"
+	KeyUseEncryption = "enc"
+)
+
+// Key types of policy keys
+const (
+	KeyTypeRSA = "RSA"
+	KeyTypeOCT = "OCT"
+)
+
+// TrustFrameworkKey is a key of a policy keyset, only the public parts are returned by the API.
+// NotBefore and Expires are unix timestamps, 0 if not set.
+type TrustFrameworkKey struct {
+	KeyID     string   `json:"kid"`
+	Use       string   `json:"use"`
+	KeyType   string   `json:"kty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	Expires   int64    `json:"exp,omitempty"`
+	N         string   `json:"n,omitempty"`
+	E         string   `json:"e,omitempty"`
+	X5t       string   `json:"x5t,omitempty"`
+	X5c       []string `json:"x5c,omitempty"`
+}
+
+// IsActive returns true if the key is valid at the given time
+func (k TrustFrameworkKey) IsActive(at time.Time) bool {
+	if k.NotBefore != 0 && at.Unix() < k.NotBefore {
+		return false
+	}
+	if k.Expires != 0 && at.Unix() >= k.Expires {
+		return false
+	}
+	return true
+}
+
+// KeySet is a policy key container, e.g. B2C_1A_TokenSigningKeyContainer
+type KeySet struct {
+	ID   string              `json:"id"`
+	Keys []TrustFrameworkKey `json:"keys"`
+}
+
+// ActiveKeys returns the keys of the keyset that are valid at the given time
+func (ks KeySet) ActiveKeys(at time.Time) []TrustFrameworkKey {
+	active := []TrustFrameworkKey{}
+	for _, key := range ks.Keys {
+		if key.IsActive(at) {
+			active = append(active, key)
+		}
+	}
+	return active
+}
+
+// KeyOptions are the properties of a new key, zero times leave the key valid without limit
+type KeyOptions struct {
+	// Use is KeyUseSignature or KeyUseEncryption
+	Use string
+	// Type is KeyTypeRSA or KeyTypeOCT, it is only used to generate keys
+	Type      string
+	NotBefore time.Time
+	Expires   time.Time
+	// ReplaceSecret allows RotateKey to generate OCT keys, see RotateKey
+	ReplaceSecret bool
+}
+
+// payload returns the request body for opts with the additional properties
+func (opts KeyOptions) payload(properties map[string]interface{}) map[string]interface{} {
+	payload := map[string]interface{}{"use": opts.Use}
+	if !opts.NotBefore.IsZero() {
+		payload["nbf"] = opts.NotBefore.Unix()
+	}
+	if !opts.Expires.IsZero() {
+		payload["exp"] = opts.Expires.Unix()
+	}
+	for name, value := range properties {
+		payload[name] = value
+	}
+	return payload
+}
+
+// ListKeySets returns all policy keysets of the tenant
+func (t Tenant) ListKeySets() ([]KeySet, error) {
+	keySets := []KeySet{}
+
+	err := t.listPages("/trustFramework/keySets", "", func(response []byte) (string, error) {
+		page := struct {
+			Value     []KeySet `json:"value"`
+			ODataNext string   `json:"@odata.nextLink"`
+		}{}
+		if err := json.Unmarshal(response, &page); err != nil {
+			return "", fmt.Errorf("error unmarshaling JSON response: %s", err)
+		}
+		keySets = append(keySets, page.Value...)
+		return page.ODataNext, nil
+	})
+	return keySets, err
+}
+
+// GetKeySet returns the keyset with the given ID and its keys
+func (t Tenant) GetKeySet(keySetID string) (KeySet, error) {
+	keySet := KeySet{}
+
+	if keySetID == "" {
+		return keySet, errors.New("keyset ID is empty")
+	}
+
+	response, err := t.callNewGraphAPI(keySetPath(keySetID), "GET", "")
+	if err != nil {
+		return keySet, fmt.Errorf("error while reading keyset %s: %s", keySetID, err)
+	}
+
+	err = json.Unmarshal(response, &keySet)
+	if err != nil {
+		return keySet, fmt.Errorf("error unmarshaling JSON response: %s", err)
+	}
+
+	return keySet, nil
+}
+
+// CreateKeySet creates an empty keyset. B2C prefixes the ID with B2C_1A_ if it doesn't start with it,
+// the returned keyset contains the actual ID.
+func (t Tenant) CreateKeySet(keySetID string) (KeySet, error) {
+	keySet := KeySet{}
+
+	if keySetID == "" {
+		return keySet, errors.New("keyset ID is empty")
+	}
+
+	payload, _ := json.Marshal(map[string]string{"id": keySetID})
+
+	response, err := t.callNewGraphAPI("/trustFramework/keySets", "POST", string(payload))
+	if err != nil {
+		return keySet, fmt.Errorf("error while creating keyset %s: %s\n%s", keySetID, err, string(response))
+	}
+
+	err = json.Unmarshal(response, &keySet)
+	if err != nil {
+		return keySet, fmt.Errorf("error unmarshaling JSON response: %s", err)
+	}
+
+	return keySet, nil
+}
+
+// DeleteKeySet deletes the keyset and all its keys
+func (t Tenant) DeleteKeySet(keySetID string) error {
+	if keySetID == "" {
+		return errors.New("keyset ID is empty")
+	}
+
+	response, err := t.callNewGraphAPI(keySetPath(keySetID), "DELETE", "")
+	if err != nil {
+		return fmt.Errorf("error while deleting keyset %s: %s\n%s", keySetID, err, string(response))
+	}
+
+	return nil
+}
+
+// GenerateKey adds a new key of opts.Type generated by B2C to the keyset
+func (t Tenant) GenerateKey(keySetID string, opts KeyOptions) (TrustFrameworkKey, error) {
+	return t.addKey(keySetID, "generateKey", opts.payload(map[string]interface{}{"kty": opts.Type}))
+}
+
+// UploadSecret adds a secret, e.g. the client secret of a social identity provider, to the keyset
+func (t Tenant) UploadSecret(keySetID string, secret string, opts KeyOptions) (TrustFrameworkKey, error) {
+	return t.addKey(keySetID, "uploadSecret", opts.payload(map[string]interface{}{"k": secret}))
+}
+
+// UploadPKCS12 adds the certificate and private key of a PKCS#12 (.pfx) file to the keyset
+func (t Tenant) UploadPKCS12(keySetID string, pfx []byte, password string) (TrustFrameworkKey, error) {
+	return t.addKey(keySetID, "uploadPkcs12", map[string]interface{}{
+		"key":      base64.StdEncoding.EncodeToString(pfx),
+		"password": password,
+	})
+}
+
+// GetActiveKey returns the key of the keyset that B2C currently uses
+func (t Tenant) GetActiveKey(keySetID string) (TrustFrameworkKey, error) {
+	key := TrustFrameworkKey{}
+
+	if keySetID == "" {
+		return key, errors.New("keyset ID is empty")
+	}
+
+	response, err := t.callNewGraphAPI(keySetPath(keySetID)+"/getActiveKey", "GET", "")
+	if err != nil {
+		return key, fmt.Errorf("error while reading active key of %s: %s", keySetID, err)
+	}
+
+	err = json.Unmarshal(response, &key)
+	if err != nil {
+		return key, fmt.Errorf("error unmarshaling JSON response: %s", err)
+	}
+
+	return key, nil
+}
+
+// RotateKey generates a new key of opts.Type and opts.Use, valid from opts.NotBefore until opts.Expires.
+// B2C switches to the new key once it becomes valid, the old key stays in the keyset until it is deleted.
+// Only use it for keysets whose keys were generated, e.g. B2C_1A_TokenSigningKeyContainer. The API doesn't tell
+// generated from uploaded keys, and a client secret of an identity provider (e.g. B2C_1A_FacebookSecret) is an OCT key,
+// so OCT keys are only generated with opts.ReplaceSecret. Rotate uploaded keys with UploadSecret or UploadPKCS12 instead.
+func (t Tenant) RotateKey(keySetID string, opts KeyOptions) (TrustFrameworkKey, error) {
+	if opts.Use == "" || opts.Type == "" {
+		return TrustFrameworkKey{}, errors.New("key use and type are required")
+	}
+
+	if !strings.EqualFold(opts.Type, KeyTypeRSA) && !strings.EqualFold(opts.Type, KeyTypeOCT) {
+		return TrustFrameworkKey{}, fmt.Errorf("keys of type %s can't be generated", opts.Type)
+	}
+
+	if strings.EqualFold(opts.Type, KeyTypeOCT) && !opts.ReplaceSecret {
+		return TrustFrameworkKey{}, fmt.Errorf("generating an OCT key in %s would replace an uploaded secret, set ReplaceSecret if the keyset contains no secret", keySetID)
+	}
+
+	if !opts.Expires.IsZero() && !opts.Expires.After(opts.NotBefore) {
+		return TrustFrameworkKey{}, fmt.Errorf("key would expire at %s before it becomes valid at %s", opts.Expires, opts.NotBefore)
+	}
+
+	active, err := t.GetActiveKey(keySetID)
+	if err != nil {
+		return TrustFrameworkKey{}, err
+	}
+
+	if !strings.EqualFold(active.KeyType, opts.Type) {
+		return TrustFrameworkKey{}, fmt.Errorf("the active key of %s is of type %s, not %s", keySetID, active.KeyType, opts.Type)
+	}
+
+	return t.GenerateKey(keySetID, opts)
+}
+
+// addKey calls one of the keyset actions that add a key
+func (t Tenant) addKey(keySetID string, action string, payload map[string]interface{}) (TrustFrameworkKey, error) {
+	key := TrustFrameworkKey{}
+
+	if keySetID == "" {
+		return key, errors.New("keyset ID is empty")
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return key, err
+	}
+
+	response, err := t.callNewGraphAPI(keySetPath(keySetID)+"/"+action, "POST", string(body))
+	if err != nil {
+		// the response may echo the request, which contains the secret, so it is not included in the error
+		return key, fmt.Errorf("error while adding key to %s: %s", keySetID, err)
+	}
+
+	err = json.Unmarshal(response, &key)
+	if err != nil {
+		return key, fmt.Errorf("error unmarshaling JSON response: %s", err)
+	}
+
+	return key, nil
+}
+
+func keySetPath(keySetID string) string {
+	return "/trustFramework/keySets/" + url.PathEscape(keySetID)
+}
@@ -0,0 +1,80 @@
+package tenant
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRotateKey(t *testing.T) {
+	var generated map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/beta/trustFramework/keySets/B2C_1A_TokenSigningKeyContainer/getActiveKey":
+			w.Write([]byte(`{"kid": "old", "use": "sig", "kty": "RSA"}`))
+		case "/beta/trustFramework/keySets/B2C_1A_TokenSigningKeyContainer/generateKey":
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &generated)
+			w.Write([]byte(`{"kid": "new", "use": "sig", "kty": "RSA"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tn := Tenant{Cloud: Cloud{LoginURL: server.URL + "/", GraphURL: server.URL + "/"}}
+
+	notBefore := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	expires := notBefore.AddDate(1, 0, 0)
+
+	opts := KeyOptions{Use: KeyUseSignature, Type: "rsa", NotBefore: notBefore, Expires: expires}
+
+	if _, err := tn.RotateKey("B2C_1A_TokenSigningKeyContainer", KeyOptions{Use: KeyUseSignature, Type: KeyTypeRSA, NotBefore: expires, Expires: notBefore}); err == nil {
+		t.Errorf("Expected error for a key expiring before it becomes valid")
+	}
+
+	if _, err := tn.RotateKey("B2C_1A_TokenSigningKeyContainer", KeyOptions{Use: KeyUseSignature, NotBefore: notBefore}); err == nil {
+		t.Errorf("Expected error for a key without type")
+	}
+
+	if _, err := tn.RotateKey("B2C_1A_TokenSigningKeyContainer", KeyOptions{Use: KeyUseSignature, Type: KeyTypeOCT}); err == nil || generated != nil {
+		t.Errorf("Expected error for an OCT key without ReplaceSecret")
+	}
+
+	if _, err := tn.RotateKey("B2C_1A_TokenSigningKeyContainer", KeyOptions{Use: KeyUseSignature, Type: KeyTypeOCT, ReplaceSecret: true}); err == nil || generated != nil {
+		t.Errorf("Expected error for an OCT key replacing an RSA key")
+	}
+
+	key, err := tn.RotateKey("B2C_1A_TokenSigningKeyContainer", opts)
+	if err != nil {
+		t.Fatalf("Error while rotating key: %s", err)
+	}
+
+	if key.KeyID != "new" || generated["use"] != "sig" || generated["kty"] != "rsa" || generated["nbf"] != float64(notBefore.Unix()) || generated["exp"] != float64(expires.Unix()) {
+		t.Errorf("Unexpected key %+v generated with %v", key, generated)
+	}
+
+	keySet := KeySet{Keys: []TrustFrameworkKey{{KeyID: "old", Expires: notBefore.Unix()}, {KeyID: "new", NotBefore: notBefore.Unix(), Expires: expires.Unix()}}}
+	if active := keySet.ActiveKeys(notBefore); len(active) != 1 || active[0].KeyID != "new" {
+		t.Errorf("Unexpected active keys %+v", active)
+	}
+}
+
+func TestListKeySets(t *testing.T) {
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
+
+	if err := tn.GetGraphAccessToken(); err != nil {
+		t.Errorf("Error while obtaining access token: %s", err)
+	}
+
+	if _, err := tn.ListKeySets(); err != nil {
+		t.Errorf("Error while listing keysets: %s", err)
+	}
+}
@@ -0,0 +1,102 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// imdsEndpoint is the token endpoint of the Azure Instance Metadata Service available on VMs
+const imdsEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+// managedIdentityTimeout limits each token request, IMDS is not reachable outside of Azure and would otherwise hang
+const managedIdentityTimeout = 10 * time.Second
+
+// ManagedIdentity obtains access tokens from the managed identity endpoint instead of using the
+// ClientID/ClientSecret of an app registration
+type ManagedIdentity struct {
+	// ClientID selects a user-assigned identity, leave empty for the system-assigned identity
+	ClientID string
+	// Endpoint overrides the token endpoint, e.g. for testing against a local stand-in.
+	// If empty, IDENTITY_ENDPOINT is used on App Service and the IMDS endpoint everywhere else.
+	Endpoint string
+	// Header is the secret sent as X-IDENTITY-HEADER to App Service style endpoints.
+	// If empty, IDENTITY_HEADER is used when IDENTITY_ENDPOINT is set.
+	Header string
+}
+
+// GetToken returns an access token for the resource of the first scope, e.g. "https://graph.microsoft.com/.default"
+func (m *ManagedIdentity) GetToken(ctx context.Context, scopes []string) (Token, error) {
+	if len(scopes) == 0 {
+		return Token{}, fmt.Errorf("no scope specified")
+	}
+
+	// managed identity endpoints only know the v1 resource, not v2.0 scopes
+	resource := strings.TrimSuffix(scopes[0], ".default")
+	endpoint, header := m.Endpoint, m.Header
+
+	if endpoint == "" {
+		endpoint = imdsEndpoint
+		if os.Getenv("IDENTITY_ENDPOINT") != "" {
+			endpoint = os.Getenv("IDENTITY_ENDPOINT")
+			if header == "" {
+				header = os.Getenv("IDENTITY_HEADER")
+			}
+		}
+	}
+
+	parameters := url.Values{"resource": {resource}}
+	if m.ClientID != "" {
+		parameters.Set("client_id", m.ClientID)
+	}
+
+	// App Service and IMDS use different API versions and authenticate the request differently
+	if header != "" {
+		parameters.Set("api-version", "2019-08-01")
+	} else {
+		parameters.Set("api-version", "2018-02-01")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint+"?"+parameters.Encode(), nil)
+	if err != nil {
+		return Token{}, err
+	}
+
+	if header != "" {
+		req.Header.Add("X-IDENTITY-HEADER", header)
+	} else {
+		req.Header.Add("Metadata", "true")
+	}
+
+	client := &http.Client{Timeout: managedIdentityTimeout}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Token{}, fmt.Errorf("error calling managed identity endpoint %s: %s", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Token{}, fmt.Errorf("error in reading the response body: %s", err)
+	}
+
+	if resp.StatusCode != 200 {
+		return Token{}, fmt.Errorf("error while calling managed identity endpoint %s: %s", endpoint, string(bodyBytes))
+	}
+
+	tr := tokenResponse{}
+
+	err = json.Unmarshal(bodyBytes, &tr)
+	if err != nil {
+		return Token{}, fmt.Errorf("Error getting the Access token: %s", err)
+	}
+
+	return tr.token(), nil
+}
@@ -0,0 +1,42 @@
+package tenant
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestManagedIdentity(t *testing.T) {
+	var gotResource, gotClientID, gotHeader, gotMetadata string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotResource = r.URL.Query().Get("resource")
+		gotClientID = r.URL.Query().Get("client_id")
+		gotHeader = r.Header.Get("X-IDENTITY-HEADER")
+		gotMetadata = r.Header.Get("Metadata")
+		w.Write([]byte(`{"access_token": "token", "token_type": "Bearer", "expires_on": "1700000000"}`))
+	}))
+	defer server.Close()
+
+	// IMDS style endpoint
+	tn := Tenant{ManagedIdentity: &ManagedIdentity{ClientID: "user-assigned", Endpoint: server.URL}}
+
+	if err := tn.GetGraphAccessToken(); err != nil {
+		t.Fatalf("Error while obtaining access token: %s", err)
+	}
+
+	if tn.AccessToken.AccessToken != "token" || gotResource != AzurePublicCloud.GraphURL || gotClientID != "user-assigned" || gotMetadata != "true" {
+		t.Errorf("Unexpected token request: resource %q, client_id %q, Metadata %q", gotResource, gotClientID, gotMetadata)
+	}
+
+	// App Service style endpoint
+	tn = Tenant{ManagedIdentity: &ManagedIdentity{Endpoint: server.URL, Header: "secret"}}
+
+	if err := tn.GetAccessToken(); err != nil {
+		t.Fatalf("Error while obtaining access token: %s", err)
+	}
+
+	if gotResource != AzurePublicCloud.AADGraphURL || gotHeader != "secret" || gotClientID != "" {
+		t.Errorf("Unexpected token request: resource %q, client_id %q, X-IDENTITY-HEADER %q", gotResource, gotClientID, gotHeader)
+	}
+}
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"crypto/sha1"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The middleware in this file protects endpoints that are called by B2C itself, e.g. REST technical profiles
+// and API connectors, with the authentication methods B2C supports.
+
+// BasicAuth returns middleware that only lets requests through that carry the given HTTP basic auth credentials
+func BasicAuth(username, password string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u, p, ok := r.BasicAuth()
+			if !ok || subtle.ConstantTimeCompare([]byte(u), []byte(username)) != 1 || subtle.ConstantTimeCompare([]byte(p), []byte(password)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="b2c"`)
+				http.Error(w, "invalid credentials", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// BearerAuth returns middleware that passes the bearer token of each request to validate
+// and only lets the request through if validate returns no error.
+// Use it with a Validator or with a comparison against a static token.
+func BearerAuth(validate func(token string) error) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := bearerToken(r)
+			if token == "" {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "missing bearer token", http.StatusUnauthorized)
+				return
+			}
+
+			if err := validate(token); err != nil {
+				log.Printf("rejected token: %s", err)
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				http.Error(w, "invalid bearer token", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientCertificateAuth returns middleware that only lets requests through that present a currently valid
+// client certificate with one of the given SHA-1 thumbprints (hex, as shown in the Azure portal).
+// The certificate is taken from the TLS connection. If the TLS connection is terminated by a proxy,
+// set header to the request header the proxy forwards the base64 encoded certificate in,
+// e.g. X-ARR-ClientCert on App Service. Only do that if the header can't be set by clients directly.
+func ClientCertificateAuth(header string, thumbprints ...string) func(http.Handler) http.Handler {
+	allowed := map[string]bool{}
+	for _, thumbprint := range thumbprints {
+		allowed[strings.ToUpper(strings.Replace(thumbprint, ":", "", -1))] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cert := clientCertificate(r, header)
+			if cert == nil {
+				http.Error(w, "missing client certificate", http.StatusUnauthorized)
+				return
+			}
+
+			now := time.Now()
+			sum := sha1.Sum(cert.Raw)
+
+			if !allowed[strings.ToUpper(hex.EncodeToString(sum[:]))] || now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+				http.Error(w, "invalid client certificate", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func clientCertificate(r *http.Request, header string) *x509.Certificate {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return r.TLS.PeerCertificates[0]
+	}
+
+	if header == "" || r.Header.Get(header) == "" {
+		return nil
+	}
+
+	der, err := base64.StdEncoding.DecodeString(r.Header.Get(header))
+	if err != nil {
+		return nil
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil
+	}
+
+	return cert
+}
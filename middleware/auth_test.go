@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+func status(h http.Handler, req *http.Request) int {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestBasicAuth(t *testing.T) {
+	h := BasicAuth("b2c", "secret")(ok)
+
+	req := httptest.NewRequest("POST", "/", nil)
+	if code := status(h, req); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without credentials, got %d", code)
+	}
+
+	req.SetBasicAuth("b2c", "wrong")
+	if code := status(h, req); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with wrong password, got %d", code)
+	}
+
+	req.SetBasicAuth("b2c", "secret")
+	if code := status(h, req); code != http.StatusOK {
+		t.Errorf("Expected 200 with valid credentials, got %d", code)
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	h := BearerAuth(func(token string) error {
+		if token != "static-token" {
+			return fmt.Errorf("unknown token")
+		}
+		return nil
+	})(ok)
+
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Header.Set("Authorization", "Bearer other-token")
+	if code := status(h, req); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with invalid token, got %d", code)
+	}
+
+	req.Header.Set("Authorization", "Bearer static-token")
+	if code := status(h, req); code != http.StatusOK {
+		t.Errorf("Expected 200 with valid token, got %d", code)
+	}
+}
+
+func TestClientCertificateAuth(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "b2c"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %s", err)
+	}
+
+	sum := sha1.Sum(der)
+	thumbprint := hex.EncodeToString(sum[:])
+
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Header.Set("X-ARR-ClientCert", base64.StdEncoding.EncodeToString(der))
+
+	if code := status(ClientCertificateAuth("", thumbprint)(ok), req); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 if the forwarded header is not trusted, got %d", code)
+	}
+
+	if code := status(ClientCertificateAuth("X-ARR-ClientCert", "0000")(ok), req); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for unknown thumbprint, got %d", code)
+	}
+
+	if code := status(ClientCertificateAuth("X-ARR-ClientCert", thumbprint)(ok), req); code != http.StatusOK {
+		t.Errorf("Expected 200 for known thumbprint, got %d", code)
+	}
+}
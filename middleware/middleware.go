@@ -0,0 +1,200 @@
+// Package middleware provides net/http middleware that authenticates B2C users by their bearer token
+// and adds their group memberships to the request context
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	tenant "github.com/karrieretutor/b2c-tenant"
+	"github.com/karrieretutor/b2c-tenant/validator"
+)
+
+// defaultCacheSize is the number of users whose groups are cached if no Cache is set
+const defaultCacheSize = 1000
+
+// defaultCacheTTL is how long the groups of a user are cached if no CacheTTL is set
+const defaultCacheTTL = 5 * time.Minute
+
+// GroupSource resolves the group memberships of a user, tenant.Tenant implements it.
+// Wrap a Tenant in your own GroupSource to refresh its access token before it expires.
+type GroupSource interface {
+	GetMemberGroupIDs(userObjectID string) ([]string, error)
+	GetGroupNames(groupIDs []string) ([]string, error)
+}
+
+// Principal is the authenticated user of a request
+type Principal struct {
+	ObjectID   string
+	Name       string
+	Emails     []string
+	GroupIDs   []string
+	GroupNames []string
+	Claims     *validator.Claims
+}
+
+// InGroup returns true if the principal is member of the group with the given display name or objectId
+func (p *Principal) InGroup(group string) bool {
+	for _, id := range p.GroupIDs {
+		if id == group {
+			return true
+		}
+	}
+	for _, name := range p.GroupNames {
+		if name == group {
+			return true
+		}
+	}
+	return false
+}
+
+// groups is the cached group membership of a user
+type groups struct {
+	ids   []string
+	names []string
+}
+
+type contextKey struct{}
+
+// Authenticator validates the bearer token of each request and puts the Principal into the request context
+type Authenticator struct {
+	Validator *validator.Validator
+	// Groups is optional, if nil the Principal contains no groups
+	Groups GroupSource
+	// Cache holds the resolved groups per user, defaults to an LRU cache of 1000 users
+	Cache tenant.Cache
+	// CacheTTL defaults to 5 minutes
+	CacheTTL time.Duration
+}
+
+// New returns an Authenticator with the default cache settings
+func New(v *validator.Validator, groupSource GroupSource) *Authenticator {
+	return &Authenticator{
+		Validator: v,
+		Groups:    groupSource,
+		Cache:     tenant.NewLRUCache(defaultCacheSize),
+		CacheTTL:  defaultCacheTTL,
+	}
+}
+
+// Handler returns a handler that rejects requests without a valid bearer token with 401
+// and calls next with the Principal in the request context otherwise
+func (a *Authenticator) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := a.Validator.Validate(token)
+		if err != nil {
+			log.Printf("rejected token: %s", err)
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, "invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		p := &Principal{
+			ObjectID: claims.ObjectID,
+			Name:     claims.Name,
+			Emails:   claims.Emails,
+			Claims:   claims,
+		}
+
+		if p.ObjectID == "" {
+			p.ObjectID = claims.Subject
+		}
+
+		if a.Groups != nil {
+			g, err := a.resolveGroups(p.ObjectID)
+			if err != nil {
+				log.Printf("error resolving groups of %s: %s", p.ObjectID, err)
+				http.Error(w, "error resolving group memberships", http.StatusInternalServerError)
+				return
+			}
+			p.GroupIDs, p.GroupNames = g.ids, g.names
+		}
+
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), p)))
+	})
+}
+
+// resolveGroups returns the groups of the user, from the cache if possible
+func (a *Authenticator) resolveGroups(objectID string) (groups, error) {
+	if a.Cache != nil {
+		if cached, ok := a.Cache.Get(objectID); ok {
+			return cached.(groups), nil
+		}
+	}
+
+	ids, err := a.Groups.GetMemberGroupIDs(objectID)
+	if err != nil {
+		return groups{}, err
+	}
+
+	names, err := a.Groups.GetGroupNames(ids)
+	if err != nil {
+		// the names of all other groups are still returned, so only the failed ones are missing
+		log.Printf("error resolving group names of %s: %s", objectID, err)
+	}
+
+	g := groups{ids: ids, names: names}
+
+	// don't cache incomplete results
+	if a.Cache != nil && err == nil {
+		ttl := a.CacheTTL
+		if ttl == 0 {
+			ttl = defaultCacheTTL
+		}
+		a.Cache.Set(objectID, g, ttl)
+	}
+
+	return g, nil
+}
+
+// NewContext returns a copy of ctx carrying the principal
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// PrincipalFromContext returns the principal put into the request context by Authenticator.Handler
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(*Principal)
+	return p, ok
+}
+
+// RequireGroup returns middleware that only lets principals through that are member of at least one of the groups,
+// given by display name or objectId. It has to be wrapped by Authenticator.Handler.
+func RequireGroup(groups ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				http.Error(w, "not authenticated", http.StatusUnauthorized)
+				return
+			}
+
+			for _, group := range groups {
+				if p.InGroup(group) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			http.Error(w, "forbidden", http.StatusForbidden)
+		})
+	}
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
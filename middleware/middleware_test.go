@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/karrieretutor/b2c-tenant/validator"
+)
+
+type fakeGroups struct {
+	calls int
+	fail  bool
+}
+
+func (f *fakeGroups) GetMemberGroupIDs(userObjectID string) ([]string, error) {
+	f.calls++
+	return []string{"g1", "g2"}, nil
+}
+
+func (f *fakeGroups) GetGroupNames(groupIDs []string) ([]string, error) {
+	if f.fail {
+		return []string{"Users"}, fmt.Errorf("error while reading 1 of 2 groups: g1")
+	}
+	return []string{"Admins", "Users"}, nil
+}
+
+// newTestValidator returns a validator backed by a fake tenant and a function signing tokens for it
+func newTestValidator(t *testing.T) (*validator.Validator, func(oid string) string, func()) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": server.URL, "jwks_uri": server.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "key1",
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	server = httptest.NewServer(mux)
+
+	sign := func(oid string) string {
+		header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "key1"})
+		payload, _ := json.Marshal(map[string]interface{}{
+			"iss": server.URL,
+			"aud": "client-id",
+			"oid": oid,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+		hash := sha256.Sum256([]byte(signingInput))
+		signature, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+		return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+	}
+
+	v, err := validator.New(validator.Config{
+		Audiences:   []string{"client-id"},
+		MetadataURL: server.URL + "/.well-known/openid-configuration",
+	})
+	if err != nil {
+		t.Fatalf("Error creating validator: %s", err)
+	}
+
+	return v, sign, server.Close
+}
+
+func TestAuthenticator(t *testing.T) {
+	v, sign, stop := newTestValidator(t)
+	defer stop()
+
+	fg := &fakeGroups{}
+	a := New(v, fg)
+
+	var principal *Principal
+	handler := a.Handler(RequireGroup("Admins")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = PrincipalFromContext(r.Context())
+	})))
+
+	tests := []struct {
+		name   string
+		auth   string
+		status int
+	}{
+		{"missing token", "", http.StatusUnauthorized},
+		{"invalid token", "Bearer invalid", http.StatusUnauthorized},
+		{"valid token", "Bearer " + sign("user1"), http.StatusOK},
+		{"cached groups", "Bearer " + sign("user1"), http.StatusOK},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.auth != "" {
+			req.Header.Set("Authorization", test.auth)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.name, test.status, rec.Code)
+		}
+	}
+
+	if principal == nil || principal.ObjectID != "user1" || !principal.InGroup("g2") {
+		t.Errorf("Unexpected principal %+v", principal)
+	}
+
+	if fg.calls != 1 {
+		t.Errorf("Expected groups to be resolved once, got %d calls", fg.calls)
+	}
+
+	// members of other groups are forbidden
+	forbidden := a.Handler(RequireGroup("Owners")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+sign("user1"))
+	rec := httptest.NewRecorder()
+	forbidden.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	// incomplete group names are not cached
+	fg.fail = true
+	if _, err := a.resolveGroups("user2"); err != nil {
+		t.Fatalf("Error resolving groups: %s", err)
+	}
+	if _, ok := a.Cache.Get("user2"); ok {
+		t.Errorf("Expected incomplete groups not to be cached")
+	}
+}
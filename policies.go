@@ -0,0 +1,77 @@
+package tenant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// TrustFrameworkPolicy is a B2C custom policy, e.g. B2C_1A_TrustFrameworkBase
+type TrustFrameworkPolicy struct {
+	ID string `json:"id"`
+}
+
+// PolicyResponse simply contains the API response (within the 'value' tag) type for our JSON unmarshaler to put the data into
+type PolicyResponse struct {
+	Value     []TrustFrameworkPolicy `json:"value"`
+	ODataNext string                 `json:"@odata.nextLink"`
+}
+
+// ListPolicies returns all custom policies of the tenant
+func (t Tenant) ListPolicies() ([]TrustFrameworkPolicy, error) {
+	policies := []TrustFrameworkPolicy{}
+
+	err := t.listPages("/trustFramework/policies", "", func(response []byte) (string, error) {
+		pr := PolicyResponse{}
+		if err := json.Unmarshal(response, &pr); err != nil {
+			return "", fmt.Errorf("error unmarshaling JSON response: %s", err)
+		}
+		policies = append(policies, pr.Value...)
+		return pr.ODataNext, nil
+	})
+	return policies, err
+}
+
+// GetPolicyXML returns the XML of the custom policy with the given ID
+func (t Tenant) GetPolicyXML(policyID string) ([]byte, error) {
+	if policyID == "" {
+		return nil, errors.New("policy ID is empty")
+	}
+
+	response, err := t.callNewGraphAPI("/trustFramework/policies/"+url.PathEscape(policyID)+"/$value", "GET", "")
+	if err != nil {
+		return nil, fmt.Errorf("error while reading policy %s: %s", policyID, err)
+	}
+
+	return response, nil
+}
+
+// UploadPolicy creates or replaces the custom policy with the given ID. The PolicyId in the XML has to match policyID
+// and the BasePolicy, if any, has to exist already.
+func (t Tenant) UploadPolicy(policyID string, policyXML []byte) error {
+	if policyID == "" {
+		return errors.New("policy ID is empty")
+	}
+
+	response, err := t.callNewGraphAPIWithContent("/trustFramework/policies/"+url.PathEscape(policyID)+"/$value", "PUT", "application/xml", policyXML)
+	if err != nil {
+		return fmt.Errorf("error while uploading policy %s: %s\n%s", policyID, err, string(response))
+	}
+
+	return nil
+}
+
+// DeletePolicy deletes the custom policy with the given ID
+func (t Tenant) DeletePolicy(policyID string) error {
+	if policyID == "" {
+		return errors.New("policy ID is empty")
+	}
+
+	response, err := t.callNewGraphAPI("/trustFramework/policies/"+url.PathEscape(policyID), "DELETE", "")
+	if err != nil {
+		return fmt.Errorf("error while deleting policy %s: %s\n%s", policyID, err, string(response))
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package tenant
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUploadPolicy(t *testing.T) {
+	var contentType, path string
+	var body []byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		path = r.URL.Path
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	tn := Tenant{Cloud: Cloud{LoginURL: server.URL + "/", GraphURL: server.URL + "/"}}
+
+	policyXML := []byte(`<TrustFrameworkPolicy PolicyId="B2C_1A_Test"/>`)
+	if err := tn.UploadPolicy("B2C_1A_Test", policyXML); err != nil {
+		t.Fatalf("Error while uploading policy: %s", err)
+	}
+
+	if contentType != "application/xml" || path != "/beta/trustFramework/policies/B2C_1A_Test/$value" || string(body) != string(policyXML) {
+		t.Errorf("Unexpected request: %s %s %s", contentType, path, body)
+	}
+}
+
+func TestListPolicies(t *testing.T) {
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
+
+	if err := tn.GetGraphAccessToken(); err != nil {
+		t.Errorf("Error while obtaining access token: %s", err)
+	}
+
+	policies, err := tn.ListPolicies()
+	if err != nil {
+		t.Fatalf("Error while listing policies: %s", err)
+	}
+
+	for _, policy := range policies {
+		if _, err := tn.GetPolicyXML(policy.ID); err != nil {
+			t.Errorf("Error while reading policy %s: %s", policy.ID, err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package tenant
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter spaces out API calls to at most a fixed number of requests per second.
+// It can be shared by several Tenant values, a nil *RateLimiter doesn't limit anything.
+type RateLimiter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	next     time.Time
+}
+
+// NewRateLimiter returns a RateLimiter allowing requestsPerSecond requests per second
+func NewRateLimiter(requestsPerSecond float64) *RateLimiter {
+	return &RateLimiter{interval: time.Duration(float64(time.Second) / requestsPerSecond)}
+}
+
+// Wait blocks until the next request is allowed
+func (r *RateLimiter) Wait() {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	wait := r.next.Sub(now)
+	r.next = r.next.Add(r.interval)
+	r.mu.Unlock()
+
+	time.Sleep(wait)
+}
@@ -0,0 +1,256 @@
+package tenant
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DesiredMemberships maps group objectIds to the complete list of users that should be members of the group.
+// A user is identified either by its objectId or by one of its email addresses (otherMails).
+type DesiredMemberships map[string][]string
+
+// ChangeAction is the kind of a MembershipChange
+type ChangeAction string
+
+// The actions a reconciliation can perform on a group
+const (
+	ActionAdd    ChangeAction = "add"
+	ActionRemove ChangeAction = "remove"
+)
+
+// MembershipChange is a single add or remove operation of a ReconcilePlan
+type MembershipChange struct {
+	Action  ChangeAction `json:"action"`
+	GroupID string       `json:"groupId"`
+	UserID  string       `json:"userId"`
+	// Identifier is the entry of DesiredMemberships the user was resolved from, empty for removals
+	Identifier string `json:"identifier,omitempty"`
+}
+
+func (c MembershipChange) String() string {
+	if c.Identifier != "" && c.Identifier != c.UserID {
+		return fmt.Sprintf("%s %s (%s) in group %s", c.Action, c.UserID, c.Identifier, c.GroupID)
+	}
+	return fmt.Sprintf("%s %s in group %s", c.Action, c.UserID, c.GroupID)
+}
+
+// ReconcilePlan contains the changes needed to bring the group memberships to the desired state
+type ReconcilePlan struct {
+	Changes []MembershipChange `json:"changes"`
+}
+
+// String returns the plan in a human readable form, one change per line, for dry-run output
+func (p ReconcilePlan) String() string {
+	if len(p.Changes) == 0 {
+		return "no changes"
+	}
+
+	lines := make([]string, len(p.Changes))
+	for i, change := range p.Changes {
+		lines[i] = change.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// ChangeResult is the outcome of applying a single MembershipChange
+type ChangeResult struct {
+	Change MembershipChange `json:"change"`
+	Err    error            `json:"-"`
+}
+
+// MarshalJSON adds the message of Err as error, errors can't be marshaled themselves
+func (r ChangeResult) MarshalJSON() ([]byte, error) {
+	result := struct {
+		Change MembershipChange `json:"change"`
+		Error  string           `json:"error,omitempty"`
+	}{Change: r.Change}
+
+	if r.Err != nil {
+		result.Error = r.Err.Error()
+	}
+
+	return json.Marshal(result)
+}
+
+// ReconcileOptions control how Reconcile applies a plan
+type ReconcileOptions struct {
+	// DryRun only computes the plan without changing any group
+	DryRun bool
+	// Concurrency is the maximum number of changes applied at the same time, defaults to groupLookupWorkers
+	Concurrency int
+}
+
+// Reconcile computes the plan for the desired memberships and applies it, unless opts.DryRun is set.
+// The returned results contain one entry per change of the plan, the error is only set if the plan could not be computed
+// or at least one change failed.
+func (t Tenant) Reconcile(desired DesiredMemberships, opts ReconcileOptions) (ReconcilePlan, []ChangeResult, error) {
+	plan, err := t.PlanReconcile(desired)
+	if err != nil {
+		return plan, nil, err
+	}
+
+	if opts.DryRun {
+		return plan, nil, nil
+	}
+
+	results := t.ApplyPlan(plan, opts.Concurrency)
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return plan, results, fmt.Errorf("%d of %d changes failed", failed, len(results))
+	}
+
+	return plan, results, nil
+}
+
+// PlanReconcile compares the desired memberships with the current members of each group and returns the
+// changes needed. Groups that are not part of desired are left untouched.
+// All email identifiers have to resolve to at least one user, otherwise no plan is returned.
+func (t Tenant) PlanReconcile(desired DesiredMemberships) (ReconcilePlan, error) {
+	plan := ReconcilePlan{}
+
+	// resolve each identifier only once, even if it is listed for several groups
+	resolved := map[string][]string{}
+
+	groupIDs := make([]string, 0, len(desired))
+	for groupID := range desired {
+		groupIDs = append(groupIDs, groupID)
+	}
+	sort.Strings(groupIDs)
+
+	for _, groupID := range groupIDs {
+		wanted := map[string]string{}
+
+		for _, identifier := range desired[groupID] {
+			userIDs, ok := resolved[identifier]
+			if !ok {
+				var err error
+				userIDs, err = t.resolveUserIdentifier(identifier)
+				if err != nil {
+					return ReconcilePlan{}, err
+				}
+				resolved[identifier] = userIDs
+			}
+
+			for _, userID := range userIDs {
+				wanted[userID] = identifier
+			}
+		}
+
+		members, err := t.GetGroupMembers(groupID)
+		if err != nil {
+			return ReconcilePlan{}, err
+		}
+
+		plan.Changes = append(plan.Changes, diffMemberships(groupID, members, wanted)...)
+	}
+
+	return plan, nil
+}
+
+// ApplyPlan applies all changes of plan with at most concurrency changes at the same time.
+// The results are in the same order as plan.Changes.
+func (t Tenant) ApplyPlan(plan ReconcilePlan, concurrency int) []ChangeResult {
+	if concurrency <= 0 {
+		concurrency = groupLookupWorkers
+	}
+
+	results := make([]ChangeResult, len(plan.Changes))
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < concurrency && w < len(plan.Changes); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				change := plan.Changes[i]
+				results[i].Change = change
+
+				switch change.Action {
+				case ActionAdd:
+					results[i].Err = t.AddGroupMemberByID(change.GroupID, change.UserID)
+				case ActionRemove:
+					results[i].Err = t.DeleteGroupMemberByID(change.GroupID, change.UserID)
+				default:
+					results[i].Err = fmt.Errorf("unknown action %q", change.Action)
+				}
+			}
+		}()
+	}
+
+	for i := range plan.Changes {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// resolveUserIdentifier returns the objectIds of the users an identifier of DesiredMemberships refers to
+func (t Tenant) resolveUserIdentifier(identifier string) ([]string, error) {
+	if !strings.Contains(identifier, "@") {
+		return []string{identifier}, nil
+	}
+
+	users, err := t.GetUsersByEmail(identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(users) == 0 {
+		return nil, fmt.Errorf("no user with email %s exists", identifier)
+	}
+
+	userIDs := make([]string, len(users))
+	for i, user := range users {
+		userIDs[i] = user.ID
+	}
+
+	return userIDs, nil
+}
+
+// diffMemberships returns the changes that turn the current members of a group into the wanted ones.
+// wanted maps user objectIds to the identifier they were resolved from.
+// Only users are removed, members of other types like nested groups or service principals are left alone.
+func diffMemberships(groupID string, current []User, wanted map[string]string) []MembershipChange {
+	changes := []MembershipChange{}
+	isMember := map[string]bool{}
+
+	for _, member := range current {
+		isMember[member.ID] = true
+		if member.ODataType != ODataUser {
+			continue
+		}
+		if _, ok := wanted[member.ID]; !ok {
+			changes = append(changes, MembershipChange{Action: ActionRemove, GroupID: groupID, UserID: member.ID})
+		}
+	}
+
+	for userID, identifier := range wanted {
+		if !isMember[userID] {
+			changes = append(changes, MembershipChange{Action: ActionAdd, GroupID: groupID, UserID: userID, Identifier: identifier})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Action != changes[j].Action {
+			return changes[i].Action < changes[j].Action
+		}
+		return changes[i].UserID < changes[j].UserID
+	})
+
+	return changes
+}
@@ -0,0 +1,76 @@
+package tenant
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestDiffMemberships(t *testing.T) {
+	current := []User{{ID: "a", ODataType: ODataUser}, {ID: "b", ODataType: ODataUser}, {ID: "nested", ODataType: "#microsoft.graph.group"}}
+	wanted := map[string]string{"b": "b", "c": "c@example.com"}
+
+	changes := diffMemberships("g", current, wanted)
+
+	expected := []MembershipChange{
+		{Action: ActionAdd, GroupID: "g", UserID: "c", Identifier: "c@example.com"},
+		{Action: ActionRemove, GroupID: "g", UserID: "a"},
+	}
+
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes, got %d: %v", len(expected), len(changes), changes)
+	}
+
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Errorf("Expected change %q, got %q", expected[i], changes[i])
+		}
+	}
+}
+
+func TestChangeResultJSON(t *testing.T) {
+	result := ChangeResult{Change: MembershipChange{Action: ActionAdd, GroupID: "g", UserID: "a"}, Err: errors.New("forbidden")}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Error marshaling result: %s", err)
+	}
+
+	if string(encoded) != `{"change":{"action":"add","groupId":"g","userId":"a"},"error":"forbidden"}` {
+		t.Errorf("Unexpected JSON %s", encoded)
+	}
+}
+
+func TestReconcileDryRun(t *testing.T) {
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
+
+	if err := tn.GetGraphAccessToken(); err != nil {
+		t.Errorf("Error while obtaining access token: %s", err)
+	}
+
+	groupID := os.Getenv("B2C_TESTGROUP")
+
+	members, err := tn.GetGroupMembers(groupID)
+	if err != nil {
+		t.Errorf("Error while reading member list: %s", err)
+	}
+
+	desired := DesiredMemberships{groupID: {}}
+	for _, member := range members {
+		desired[groupID] = append(desired[groupID], member.ID)
+	}
+
+	// the current members are the desired state, so there must be nothing to do
+	plan, results, err := tn.Reconcile(desired, ReconcileOptions{DryRun: true})
+	if err != nil {
+		t.Errorf("Error while planning reconciliation: %s", err)
+	}
+
+	if len(plan.Changes) != 0 || len(results) != 0 {
+		t.Errorf("Expected empty plan, got:\n%s", plan)
+	}
+}
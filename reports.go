@@ -0,0 +1,313 @@
+package tenant
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// The B2C usage reports of the Azure AD Graph API
+const (
+	ReportAuthenticationCount        = "b2cAuthenticationCount"
+	ReportAuthenticationCountSummary = "b2cAuthenticationCountSummary"
+	ReportMFARequestCount            = "b2cMfaRequestCount"
+	ReportMFARequestCountSummary     = "b2cMfaRequestCountSummary"
+	ReportTenantUserCount            = "tenantUserCount"
+)
+
+// reportDateFormat is the date format of the TimeStamp filter of the usage reports
+const reportDateFormat = "2006-01-02"
+
+// reportTimeFormats are the formats the usage reports use for timestamps
+var reportTimeFormats = []string{time.RFC3339, "2006-01-02T15:04:05", reportDateFormat}
+
+// UsageRecord is an entry of a B2C usage report. Depending on the report, it is broken down by application,
+// policy or authentication type, the other fields are empty then.
+type UsageRecord struct {
+	Date    time.Time
+	EndDate time.Time
+	Count   float64
+
+	ApplicationID string
+	PolicyID      string
+	// Type is the authentication or MFA type, e.g. "SMS"
+	Type string
+}
+
+// usageRecordJSON contains the field names the different reports use
+type usageRecordJSON struct {
+	TimeStamp           string   `json:"TimeStamp"`
+	StartTimeStamp      string   `json:"StartTimeStamp"`
+	EndTimeStamp        string   `json:"EndTimeStamp"`
+	AuthenticationCount *float64 `json:"AuthenticationCount"`
+	MfaCount            *float64 `json:"MfaCount"`
+	MfaRequestCount     *float64 `json:"MfaRequestCount"`
+	ApplicationID       string   `json:"ApplicationId"`
+	PolicyID            string   `json:"PolicyId"`
+	AuthenticationType  string   `json:"AuthenticationType"`
+	MfaType             string   `json:"MfaType"`
+}
+
+// UnmarshalJSON reads the record from any of the B2C usage reports
+func (r *UsageRecord) UnmarshalJSON(data []byte) error {
+	raw := usageRecordJSON{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	start := raw.StartTimeStamp
+	if start == "" {
+		start = raw.TimeStamp
+	}
+
+	var err error
+	if r.Date, err = parseReportTime(start); err != nil {
+		return err
+	}
+	if r.EndDate, err = parseReportTime(raw.EndTimeStamp); err != nil {
+		return err
+	}
+
+	for _, count := range []*float64{raw.AuthenticationCount, raw.MfaCount, raw.MfaRequestCount} {
+		if count != nil {
+			r.Count = *count
+			break
+		}
+	}
+
+	r.ApplicationID = raw.ApplicationID
+	r.PolicyID = raw.PolicyID
+	r.Type = raw.AuthenticationType
+	if r.Type == "" {
+		r.Type = raw.MfaType
+	}
+
+	return nil
+}
+
+// UserCountRecord is an entry of the tenantUserCount report
+type UserCountRecord struct {
+	Date           time.Time
+	TotalUserCount float64
+	// LocalUserCount are the users signing in with a local account, OtherUserCount the users of social identity providers
+	LocalUserCount float64
+	OtherUserCount float64
+}
+
+// UnmarshalJSON reads the record from the tenantUserCount report
+func (r *UserCountRecord) UnmarshalJSON(data []byte) error {
+	raw := struct {
+		TimeStamp      string  `json:"TimeStamp"`
+		TotalUserCount float64 `json:"TotalUserCount"`
+		LocalUserCount float64 `json:"LocalUserCount"`
+		OtherUserCount float64 `json:"OtherUserCount"`
+	}{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	date, err := parseReportTime(raw.TimeStamp)
+	if err != nil {
+		return err
+	}
+
+	*r = UserCountRecord{Date: date, TotalUserCount: raw.TotalUserCount, LocalUserCount: raw.LocalUserCount, OtherUserCount: raw.OtherUserCount}
+	return nil
+}
+
+// UsageSeries is a time series of usage records
+type UsageSeries []UsageRecord
+
+// Sorted returns a copy of the series sorted by date
+func (s UsageSeries) Sorted() UsageSeries {
+	sorted := append(UsageSeries{}, s...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+	return sorted
+}
+
+// Total returns the sum of all counts of the series
+func (s UsageSeries) Total() float64 {
+	total := 0.0
+	for _, r := range s {
+		total += r.Count
+	}
+	return total
+}
+
+// ByApplication splits the series by the application the records belong to
+func (s UsageSeries) ByApplication() map[string]UsageSeries {
+	return s.groupBy(func(r UsageRecord) string { return r.ApplicationID })
+}
+
+// ByPolicy splits the series by the policy the records belong to
+func (s UsageSeries) ByPolicy() map[string]UsageSeries {
+	return s.groupBy(func(r UsageRecord) string { return r.PolicyID })
+}
+
+func (s UsageSeries) groupBy(key func(UsageRecord) string) map[string]UsageSeries {
+	groups := map[string]UsageSeries{}
+	for _, r := range s {
+		groups[key(r)] = append(groups[key(r)], r)
+	}
+	return groups
+}
+
+// RollingToDaily converts a series of rolling window totals, e.g. the 30 day count returned by
+// GetB2CAuthenticationCount recorded once a day, into daily counts. The series has to contain one
+// record per day without gaps.
+//
+// The difference of two consecutive totals is the count of the new day minus the count of the day that
+// dropped out of the window. The days before the first total aren't known, so they are assumed to be
+// evenly distributed over the window, which makes the first window of daily counts an estimate.
+func RollingToDaily(rolling UsageSeries, window int) (UsageSeries, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("invalid window of %d days", window)
+	}
+
+	rolling = rolling.Sorted()
+	if len(rolling) == 0 {
+		return UsageSeries{}, nil
+	}
+
+	// the estimated daily counts of the first window, followed by the computed daily counts
+	estimate := rolling[0].Count / float64(window)
+	counts := make([]float64, window-1, window-1+len(rolling))
+	for i := range counts {
+		counts[i] = estimate
+	}
+
+	daily := make(UsageSeries, len(rolling))
+
+	for i, r := range rolling {
+		if i > 0 && !sameDay(r.Date, rolling[i-1].Date.AddDate(0, 0, 1)) {
+			return nil, fmt.Errorf("series is not daily: %s follows %s", r.Date.Format(reportDateFormat), rolling[i-1].Date.Format(reportDateFormat))
+		}
+
+		count := estimate
+		if i > 0 {
+			count = r.Count - rolling[i-1].Count + counts[len(counts)-window]
+		}
+		counts = append(counts, count)
+
+		daily[i] = r
+		daily[i].Count = count
+	}
+
+	return daily, nil
+}
+
+// GetUsageReport returns the records of a B2C usage report between from and to (inclusive, by day).
+// With zero times the default period of the report is returned, usually the last 30 days.
+func (t Tenant) GetUsageReport(report string, from, to time.Time) (UsageSeries, error) {
+	rr := struct {
+		Value UsageSeries `json:"value"`
+	}{}
+
+	if err := t.getReport(report, from, to, &rr); err != nil {
+		return nil, err
+	}
+
+	return rr.Value.Sorted(), nil
+}
+
+// GetAuthenticationCounts returns the daily authentication counts between from and to
+func (t Tenant) GetAuthenticationCounts(from, to time.Time) (UsageSeries, error) {
+	return t.GetUsageReport(ReportAuthenticationCountSummary, from, to)
+}
+
+// GetMFARequestCounts returns the daily MFA request counts between from and to
+func (t Tenant) GetMFARequestCounts(from, to time.Time) (UsageSeries, error) {
+	return t.GetUsageReport(ReportMFARequestCountSummary, from, to)
+}
+
+// GetAuthenticationCountsByApplication returns the authentication counts between from and to per application ID
+func (t Tenant) GetAuthenticationCountsByApplication(from, to time.Time) (map[string]UsageSeries, error) {
+	series, err := t.GetUsageReport(ReportAuthenticationCount, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return series.ByApplication(), nil
+}
+
+// GetAuthenticationCountsByPolicy returns the authentication counts between from and to per policy ID
+func (t Tenant) GetAuthenticationCountsByPolicy(from, to time.Time) (map[string]UsageSeries, error) {
+	series, err := t.GetUsageReport(ReportAuthenticationCount, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return series.ByPolicy(), nil
+}
+
+// GetTenantUserCounts returns the daily user counts of the tenant between from and to
+func (t Tenant) GetTenantUserCounts(from, to time.Time) ([]UserCountRecord, error) {
+	rr := struct {
+		Value []UserCountRecord `json:"value"`
+	}{}
+
+	if err := t.getReport(ReportTenantUserCount, from, to, &rr); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(rr.Value, func(i, j int) bool { return rr.Value[i].Date.Before(rr.Value[j].Date) })
+	return rr.Value, nil
+}
+
+// getReport reads a usage report from the Azure AD Graph API into v
+func (t Tenant) getReport(report string, from, to time.Time, v interface{}) error {
+	param := ""
+	if filter := reportFilter(from, to); filter != "" {
+		param = "$filter=" + url.QueryEscape(filter)
+	}
+
+	response, err := t.callGraphAPI("/reports/"+report+"/", "beta", "GET", param)
+	if err != nil {
+		msg := "Error in calling API: " + err.Error()
+		log.Println(msg)
+		return fmt.Errorf("error while reading report %s: %s", report, err)
+	}
+
+	if err := json.Unmarshal(response, v); err != nil {
+		return fmt.Errorf("error unmarshaling JSON response: %s", err)
+	}
+
+	return nil
+}
+
+// reportFilter returns the TimeStamp filter for the period between from and to, zero times are left open
+func reportFilter(from, to time.Time) string {
+	filter := ""
+	if !from.IsZero() {
+		filter = "TimeStamp ge " + from.UTC().Format(reportDateFormat)
+	}
+	if !to.IsZero() {
+		if filter != "" {
+			filter += " and "
+		}
+		filter += "TimeStamp le " + to.UTC().Format(reportDateFormat)
+	}
+	return filter
+}
+
+// parseReportTime parses the timestamps of the usage reports, "" is the zero time
+func parseReportTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range reportTimeFormats {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid report timestamp %q", value)
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
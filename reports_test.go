@@ -0,0 +1,72 @@
+package tenant
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestUsageRecordUnmarshal(t *testing.T) {
+	page := `{"value": [
+		{"StartTimeStamp": "2020-03-02T00:00:00Z", "EndTimeStamp": "2020-03-03T00:00:00Z", "AuthenticationCount": 7, "ApplicationId": "app2", "PolicyId": "B2C_1_signin"},
+		{"TimeStamp": "2020-03-01", "MfaCount": 3, "MfaType": "SMS"}
+	]}`
+
+	rr := struct {
+		Value UsageSeries `json:"value"`
+	}{}
+	if err := json.Unmarshal([]byte(page), &rr); err != nil {
+		t.Fatalf("Error unmarshaling report: %s", err)
+	}
+
+	series := rr.Value.Sorted()
+	if len(series) != 2 || series[0].Count != 3 || series[0].Type != "SMS" || series[1].Count != 7 || series[1].EndDate.Day() != 3 {
+		t.Errorf("Unexpected records: %+v", series)
+	}
+
+	if byApp := series.ByApplication(); len(byApp) != 2 || byApp["app2"].Total() != 7 {
+		t.Errorf("Unexpected records by application: %+v", byApp)
+	}
+}
+
+func TestRollingToDaily(t *testing.T) {
+	start := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	daily := []float64{2, 2, 2, 5, 1, 0, 4}
+
+	// rolling totals over a 3 day window, the days before the start had 2 each
+	rolling := UsageSeries{}
+	for i := 2; i < len(daily); i++ {
+		rolling = append(rolling, UsageRecord{Date: start.AddDate(0, 0, i), Count: daily[i-2] + daily[i-1] + daily[i]})
+	}
+
+	result, err := RollingToDaily(rolling, 3)
+	if err != nil {
+		t.Fatalf("Error converting series: %s", err)
+	}
+
+	for i, r := range result {
+		if math.Abs(r.Count-daily[i+2]) > 1e-9 || !r.Date.Equal(rolling[i].Date) {
+			t.Errorf("Day %d: expected %.0f, got %+v", i, daily[i+2], r)
+		}
+	}
+
+	if _, err := RollingToDaily(UsageSeries{rolling[0], rolling[2]}, 3); err == nil {
+		t.Errorf("Expected an error for a series with gaps")
+	}
+}
+
+func TestGetAuthenticationCounts(t *testing.T) {
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
+
+	if err := tn.GetAccessToken(); err != nil {
+		t.Errorf("Error while obtaining access token: %s", err)
+	}
+
+	if _, err := tn.GetAuthenticationCounts(time.Now().AddDate(0, 0, -7), time.Now()); err != nil {
+		t.Errorf("Error while reading authentication counts: %s", err)
+	}
+}
@@ -0,0 +1,101 @@
+// Package restapi provides ready-made handlers for RESTful technical profiles of B2C custom policies
+package restapi
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/karrieretutor/b2c-tenant/middleware"
+)
+
+// defaultClaim is the output claim the groups are returned in if GroupsHandler.Claim is empty
+const defaultClaim = "groups"
+
+// GroupsRequest contains the input claims sent by the technical profile
+type GroupsRequest struct {
+	ObjectID string `json:"objectId"`
+}
+
+// ErrorResponse is the error payload B2C shows to the user, it has to be sent with status 409
+type ErrorResponse struct {
+	Version     string `json:"version"`
+	Status      int    `json:"status"`
+	UserMessage string `json:"userMessage"`
+}
+
+// GroupsHandler is the handler for a /getMemberGroups/ RESTful technical profile. It reads the objectId input claim
+// and returns the groups of the user as string collection output claim.
+//
+// Protect it with the authentication configured in the technical profile, e.g.
+//
+//	http.Handle("/getMemberGroups/", middleware.BasicAuth(user, password)(restapi.NewGroupsHandler(tn)))
+type GroupsHandler struct {
+	Groups middleware.GroupSource
+	// Claim is the name of the output claim, "groups" by default. It has to match the PartnerClaimType in the policy.
+	Claim string
+	// Names returns the display names of the groups instead of their objectIds
+	Names bool
+	// UserMessage is shown to the user if the groups can't be resolved
+	UserMessage string
+}
+
+// NewGroupsHandler returns a GroupsHandler returning the group objectIds in the "groups" claim
+func NewGroupsHandler(groupSource middleware.GroupSource) *GroupsHandler {
+	return &GroupsHandler{
+		Groups:      groupSource,
+		Claim:       defaultClaim,
+		UserMessage: "Your group memberships could not be determined, please try again later.",
+	}
+}
+
+func (h *GroupsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		WriteError(w, "Only POST requests are supported.")
+		return
+	}
+
+	req := GroupsRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ObjectID == "" {
+		log.Printf("invalid input claims: %v", err)
+		WriteError(w, "The objectId input claim is missing.")
+		return
+	}
+
+	// the names are resolved from the objectIds, so both cases share the membership lookup
+	groups, err := h.Groups.GetMemberGroupIDs(req.ObjectID)
+	if err == nil && h.Names {
+		groups, err = h.Groups.GetGroupNames(groups)
+	}
+
+	if err != nil {
+		log.Printf("error reading groups of %s: %s", req.ObjectID, err)
+		WriteError(w, h.UserMessage)
+		return
+	}
+
+	// B2C rejects null for string collections, so always send a list
+	if groups == nil {
+		groups = []string{}
+	}
+
+	claim := h.Claim
+	if claim == "" {
+		claim = defaultClaim
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{claim: groups})
+}
+
+// WriteError sends a B2C compatible error response with status 409, which makes B2C show userMessage
+func WriteError(w http.ResponseWriter, userMessage string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(ErrorResponse{
+		Version:     "1.0.0",
+		Status:      http.StatusConflict,
+		UserMessage: userMessage,
+	})
+}
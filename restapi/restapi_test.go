@@ -0,0 +1,87 @@
+package restapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeGroups struct {
+	err error
+}
+
+func (f fakeGroups) GetMemberGroupIDs(userObjectID string) ([]string, error) {
+	return []string{"g1", "g2"}, f.err
+}
+
+func (f fakeGroups) GetGroupNames(groupIDs []string) ([]string, error) {
+	names := map[string]string{"g1": "Admins", "g2": "Users"}
+
+	groups := []string{}
+	for _, id := range groupIDs {
+		groups = append(groups, names[id])
+	}
+	return groups, f.err
+}
+
+func TestGroupsHandler(t *testing.T) {
+	h := NewGroupsHandler(fakeGroups{})
+	h.Names = true
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/getMemberGroups/", strings.NewReader(`{"objectId": "user1"}`)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	response := map[string][]string{}
+	json.NewDecoder(rec.Body).Decode(&response)
+
+	if strings.Join(response["groups"], " ") != "Admins Users" {
+		t.Errorf("Unexpected response %v", response)
+	}
+
+	// without Names the objectIds are returned as they are
+	h.Names = false
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/getMemberGroups/", strings.NewReader(`{"objectId": "user1"}`)))
+
+	response = map[string][]string{}
+	json.NewDecoder(rec.Body).Decode(&response)
+
+	if strings.Join(response["groups"], " ") != "g1 g2" {
+		t.Errorf("Unexpected response %v", response)
+	}
+
+	requests := map[string]*http.Request{
+		"missing objectId": httptest.NewRequest("POST", "/getMemberGroups/", strings.NewReader(`{}`)),
+		"GET request":      httptest.NewRequest("GET", "/getMemberGroups/", nil),
+	}
+
+	for name, req := range requests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusConflict {
+			t.Errorf("%s: expected status 409, got %d", name, rec.Code)
+		}
+	}
+
+	// API errors are reported in the B2C error format
+	h = NewGroupsHandler(fakeGroups{err: fmt.Errorf("API down")})
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/getMemberGroups/", strings.NewReader(`{"objectId": "user1"}`)))
+
+	er := ErrorResponse{}
+	json.NewDecoder(rec.Body).Decode(&er)
+
+	if rec.Code != http.StatusConflict || er.Status != http.StatusConflict || er.Version != "1.0.0" || er.UserMessage == "" {
+		t.Errorf("Unexpected error response %d %+v", rec.Code, er)
+	}
+}
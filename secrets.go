@@ -0,0 +1,120 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+)
+
+// DefaultSecretLifetime is the lifetime of secrets created by RotateClientSecret if none is set
+const DefaultSecretLifetime = 365 * 24 * time.Hour
+
+// DefaultSecretVerifyTimeout is how long RotateClientSecret tries to obtain a token with a new secret,
+// new secrets take a while until they are accepted everywhere
+const DefaultSecretVerifyTimeout = 2 * time.Minute
+
+// secretVerifyInterval is the delay between two attempts to obtain a token with a new secret
+var secretVerifyInterval = 10 * time.Second
+
+// SecretRotationOptions control RotateClientSecret
+type SecretRotationOptions struct {
+	// DisplayName is the description of the new secret shown in the portal
+	DisplayName string
+	// Lifetime defaults to DefaultSecretLifetime
+	Lifetime time.Duration
+	// VerifyTimeout defaults to DefaultSecretVerifyTimeout
+	VerifyTimeout time.Duration
+	// RemoveKeyID is the key ID of the old secret, it is removed once the new secret has been verified
+	RemoveKeyID string
+}
+
+// AddPassword creates a new client secret for the app registration with the given object ID.
+// The returned credential contains the secret in SecretText, it can't be read again later.
+func (t Tenant) AddPassword(objectID string, displayName string, expires time.Time) (PasswordCredential, error) {
+	created := PasswordCredential{}
+
+	if objectID == "" {
+		return created, errors.New("object ID is empty")
+	}
+
+	payload := map[string]PasswordCredential{"passwordCredential": {
+		DisplayName: displayName,
+		EndDateTime: expires.UTC().Format(time.RFC3339),
+	}}
+
+	err := t.postObject("/applications/"+url.PathEscape(objectID)+"/addPassword", payload, &created)
+	return created, err
+}
+
+// RemovePassword removes the client secret with the given key ID from the app registration
+func (t Tenant) RemovePassword(objectID string, keyID string) error {
+	if keyID == "" {
+		return errors.New("key ID is empty")
+	}
+
+	return t.sendObject("/applications/"+url.PathEscape(objectID)+"/removePassword", "POST", "removing a secret from", objectID, map[string]string{"keyId": keyID})
+}
+
+// RotateClientSecret adds a new client secret to the app registration of t.ClientID and verifies it by obtaining a token with it.
+// Store the returned SecretText and use it as ClientSecret from now on, t itself is not changed.
+// If verification fails or ctx is canceled, the new secret is removed again and the old secret is kept.
+// The app needs the Application.ReadWrite.OwnedBy permission and has to be an owner of its own app registration.
+func (t Tenant) RotateClientSecret(ctx context.Context, opts SecretRotationOptions) (PasswordCredential, error) {
+	if opts.Lifetime == 0 {
+		opts.Lifetime = DefaultSecretLifetime
+	}
+	if opts.VerifyTimeout == 0 {
+		opts.VerifyTimeout = DefaultSecretVerifyTimeout
+	}
+
+	application, err := t.GetApplicationByAppID(t.ClientID)
+	if err != nil {
+		return PasswordCredential{}, err
+	}
+
+	created, err := t.AddPassword(application.ID, opts.DisplayName, time.Now().Add(opts.Lifetime))
+	if err != nil {
+		return created, err
+	}
+
+	if err := t.verifyClientSecret(ctx, created.SecretText, opts.VerifyTimeout); err != nil {
+		if removeErr := t.RemovePassword(application.ID, created.KeyID); removeErr != nil {
+			return created, fmt.Errorf("new secret %s could not be verified: %s, removing it failed: %s", created.KeyID, err, removeErr)
+		}
+		return PasswordCredential{}, fmt.Errorf("new secret %s could not be verified and was removed: %s", created.KeyID, err)
+	}
+
+	if opts.RemoveKeyID != "" && opts.RemoveKeyID != created.KeyID {
+		if err := t.RemovePassword(application.ID, opts.RemoveKeyID); err != nil {
+			return created, fmt.Errorf("new secret %s is valid, but the old secret could not be removed: %s", created.KeyID, err)
+		}
+	}
+
+	return created, nil
+}
+
+// verifyClientSecret tries to obtain a Microsoft Graph token with secret until it succeeds, timeout has passed or ctx is canceled
+func (t Tenant) verifyClientSecret(ctx context.Context, secret string, timeout time.Duration) error {
+	cred := ClientSecretCredential{TenantDomain: t.authority(), ClientID: t.ClientID, ClientSecret: secret, Cloud: t.Cloud}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		_, err := cred.GetToken(ctx, []string{t.cloud().GraphURL + ".default"})
+		if err == nil {
+			return nil
+		}
+
+		log.Printf("New secret not accepted yet, retrying in %s: %s", secretVerifyInterval, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(secretVerifyInterval):
+		}
+	}
+}
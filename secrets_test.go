@@ -0,0 +1,72 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRotateClientSecret(t *testing.T) {
+	defer func(interval time.Duration) { secretVerifyInterval = interval }(secretVerifyInterval)
+	secretVerifyInterval = 0
+
+	tokenRequests := 0
+	accept := true
+	removed := ""
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/example.onmicrosoft.com/oauth2/v2.0/token":
+			tokenRequests++
+			r.ParseForm()
+			if !accept || tokenRequests == 1 || r.PostForm.Get("client_secret") != "new-secret" {
+				http.Error(w, `{"error": "invalid_client"}`, http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"access_token": "token", "token_type": "Bearer", "expires_in": 3600}`))
+		case "/beta/applications":
+			w.Write([]byte(`{"value": [{"id": "obj1", "appId": "client-id"}]}`))
+		case "/beta/applications/obj1/addPassword":
+			w.Write([]byte(`{"keyId": "new-key", "secretText": "new-secret"}`))
+		case "/beta/applications/obj1/removePassword":
+			body, _ := ioutil.ReadAll(r.Body)
+			payload := map[string]string{}
+			json.Unmarshal(body, &payload)
+			removed = payload["keyId"]
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tn := Tenant{
+		ClientID:     "client-id",
+		TenantDomain: "example.onmicrosoft.com",
+		Cloud:        Cloud{LoginURL: server.URL + "/", GraphURL: server.URL + "/"},
+	}
+
+	created, err := tn.RotateClientSecret(context.Background(), SecretRotationOptions{DisplayName: "rotated", RemoveKeyID: "old-key"})
+	if err != nil {
+		t.Fatalf("Error while rotating secret: %s", err)
+	}
+
+	if created.SecretText != "new-secret" || tokenRequests != 2 || removed != "old-key" {
+		t.Errorf("Unexpected rotation: %+v after %d token requests, removed %q", created, tokenRequests, removed)
+	}
+
+	accept = false
+	removed = ""
+
+	if _, err := tn.RotateClientSecret(context.Background(), SecretRotationOptions{VerifyTimeout: 50 * time.Millisecond, RemoveKeyID: "old-key"}); err == nil {
+		t.Errorf("Expected error for a secret that is not accepted")
+	}
+
+	if removed != "new-key" {
+		t.Errorf("Expected the unverified secret to be removed, removed %q", removed)
+	}
+}
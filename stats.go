@@ -0,0 +1,82 @@
+package tenant
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MFACountResponse simply contains the API response (within the 'value' tag) type for our JSON unmarshaler to put the data into
+type MFACountResponse struct {
+	Value []MFARequestCount `json:"value"`
+}
+
+// The MFARequestCount struct contains the details from the B2C MFA request count report
+type MFARequestCount struct {
+	B2CMFARequestCount float64 `json:"MfaCount"`
+}
+
+// GetB2CMFARequestCount returns the count of B2C multi-factor authentication requests in the last 30 days
+func (t Tenant) GetB2CMFARequestCount() (float64, error) {
+	ar, err := t.callGraphAPI("/reports/b2cMfaRequestCount/", "beta", "GET", "")
+	if err != nil {
+		msg := "Error in calling API: " + err.Error()
+		log.Println(msg)
+		return 0, fmt.Errorf("error while reading MFA request count: %s", err)
+	}
+
+	mcr := MFACountResponse{}
+
+	err = json.Unmarshal(ar, &mcr)
+	if err != nil {
+		return 0, fmt.Errorf("error unmarshaling JSON response: %s", err)
+	}
+
+	if len(mcr.Value) == 0 {
+		return 0, fmt.Errorf("MFA request count report is empty")
+	}
+
+	return mcr.Value[0].B2CMFARequestCount, nil
+}
+
+// CountUsers returns the number of users in the B2C directory that were created after since.
+// With the zero time, all users are counted.
+func (t Tenant) CountUsers(since time.Time) (int, error) {
+	filter := ""
+	if !since.IsZero() {
+		filter = "createdDateTime ge " + since.UTC().Format(time.RFC3339)
+	}
+
+	return t.countObjects("/users", filter)
+}
+
+// CountGroups returns the number of groups in the B2C directory
+func (t Tenant) CountGroups() (int, error) {
+	return t.countObjects("/groups", "")
+}
+
+// countObjects returns the $count of a Microsoft Graph collection, optionally filtered.
+// $count is an advanced query, which requires the ConsistencyLevel header, so the count may lag behind a bit.
+func (t Tenant) countObjects(endpoint string, filter string) (int, error) {
+	requestString := t.graphURL() + endpoint + "/$count"
+	if filter != "" {
+		requestString += "?$filter=" + url.QueryEscape(filter)
+	}
+
+	response, err := t.sendContent("GET", requestString, http.Header{"ConsistencyLevel": {"eventual"}}, "", nil)
+	if err != nil {
+		return 0, fmt.Errorf("error while counting %s: %s", endpoint, err)
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(string(response)))
+	if err != nil {
+		return 0, fmt.Errorf("error parsing count of %s: %s", endpoint, err)
+	}
+
+	return count, nil
+}
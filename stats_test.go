@@ -0,0 +1,46 @@
+package tenant
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCountUsers(t *testing.T) {
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
+
+	if err := tn.GetGraphAccessToken(); err != nil {
+		t.Errorf("Error while obtaining access token: %s", err)
+	}
+
+	total, err := tn.CountUsers(time.Time{})
+	if err != nil {
+		t.Fatalf("Error while counting users: %s", err)
+	}
+
+	recent, err := tn.CountUsers(time.Now().Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("Error while counting recent users: %s", err)
+	}
+
+	if total == 0 || recent > total {
+		t.Errorf("Unexpected user counts: %d total, %d recent", total, recent)
+	}
+}
+
+func TestGetB2CMFARequestCount(t *testing.T) {
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
+
+	if err := tn.GetAccessToken(); err != nil {
+		t.Errorf("Error while obtaining access token: %s", err)
+	}
+
+	if _, err := tn.GetB2CMFARequestCount(); err != nil {
+		t.Errorf("Error while reading MFA request count: %s", err)
+	}
+}
@@ -1,24 +1,57 @@
 package tenant
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
-	"strings"
+	"strconv"
+	"time"
 )
 
-const loginURL string = "https://login.microsoftonline.com/"
-
 // Tenant contains the data of the app registration in Azure AD that has write permissions in the AAD tenant
 // also the Access Token that gets returned and will be used for accessing the API
 type Tenant struct {
 	ClientID     string
 	ClientSecret string
 	TenantDomain string
-	AccessToken  AccessToken
+	// TenantID is optional, if set it is used instead of TenantDomain to request tokens
+	TenantID    string
+	AccessToken AccessToken
+
+	// Cloud defaults to AzurePublicCloud
+	Cloud Cloud
+	// GraphVersion is the Microsoft Graph API version, "beta" if empty
+	GraphVersion string
+	// MaxRetries is how often throttled or unavailable API calls are retried, 0 disables retries
+	MaxRetries int
+	// RateLimiter is optional, if set all API calls wait for it
+	RateLimiter *RateLimiter
+
+	// ClientCertificate is used instead of ClientSecret to authenticate the app registration if it is set
+	ClientCertificate *ClientCertificate
+	// FederatedTokenFile is the path of a federated identity token, e.g. from AZURE_FEDERATED_TOKEN_FILE.
+	// If it is set, the token is read on every token request and used instead of ClientSecret or ClientCertificate
+	// to obtain tokens from the v2.0 endpoint, see FederatedCredential.
+	FederatedTokenFile string
+	// ManagedIdentity is used to obtain the tokens if it is set, ClientID and the other credentials are ignored then
+	ManagedIdentity *ManagedIdentity
+	// Credential takes precedence over all other credentials, e.g. NewEnvironmentCredential or a custom TokenCredential
+	Credential TokenCredential
+
+	// Cache is optional, if set, users, groups and group memberships are served from it until the entries expire
+	Cache Cache
+	// CacheTTLs overrides DefaultCacheTTLs for all non-zero durations
+	CacheTTLs CacheTTLs
+	// GroupCache is only used for group lookups if Cache is nil.
+	//
+	// Deprecated: set Cache instead.
+	GroupCache *GroupCache
 }
 
 // AccessToken contains an OAuth2 access token for use with Azure AD Graph API calls
@@ -37,22 +70,39 @@ type AuthenticationCount struct {
 	B2CAuthenticationCount float64 `json:"AuthenticationCount"`
 }
 
+// APIError is returned by the API call helpers if the API responds with an error status code
+type APIError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Failed API call; status code: %s", e.Status)
+}
+
 // GetB2CAuthenticationCount returns the count of B2C authentications in the last 30 days
 // Unfortunately, the 30 days is a limit of the upstream API
-// so we need a way to account for that in a rolling fashion in Prometheus
+// so we need a way to account for that in a rolling fashion in Prometheus, or use GetAuthenticationCounts
+// and RollingToDaily for daily numbers
 func (t Tenant) GetB2CAuthenticationCount() (float64, error) {
 
 	ar, err := t.callGraphAPI("/reports/b2cAuthenticationCount/", "beta", "GET", "")
 	if err != nil {
 		msg := "Error in calling API: " + err.Error()
 		log.Println(msg)
+		return 0, fmt.Errorf("error while reading authentication count: %s", err)
 	}
 
 	acr := AuthCountResponse{}
 
 	err = json.Unmarshal(ar, &acr)
 	if err != nil {
-		fmt.Println(err)
+		return 0, fmt.Errorf("error unmarshaling JSON response: %s", err)
+	}
+
+	if len(acr.Value) == 0 {
+		return 0, fmt.Errorf("authentication count report is empty")
 	}
 
 	return acr.Value[0].B2CAuthenticationCount, nil
@@ -60,178 +110,191 @@ func (t Tenant) GetB2CAuthenticationCount() (float64, error) {
 
 // CallGraphAPI does the API call to the Azure AD Graph API and returns the response as an APIRespone struct
 func (t Tenant) callGraphAPI(endpoint string, apiversion string, method string, param string) ([]byte, error) {
-	requestString := "https://graph.windows.net/" + t.TenantDomain + endpoint + "?api-version=" + apiversion
-
-	// fmt.Println(requestString)
-
-	client := &http.Client{}
+	requestString := t.cloud().AADGraphURL + t.TenantDomain + endpoint + "?api-version=" + apiversion
 
 	if method == "GET" && param != "" {
 		requestString = requestString + "&" + param
 	}
 
-	req, err := http.NewRequest(method, requestString, nil)
+	return t.sendRequest(method, requestString, param)
+}
 
-	if method == "POST" && param != "" {
-		postReader := strings.NewReader(param)
-		req, err = http.NewRequest(method, requestString, postReader)
-	}
+// CallNewGraphAPI does the API call to the Azure AD Graph API and returns the response as an APIRespone struct
+func (t Tenant) callNewGraphAPI(endpoint string, method string, param string) ([]byte, error) {
+	requestString := t.graphURL() + endpoint
 
-	if err != nil {
-		fmt.Println(err)
-		return []byte{}, err
+	if method == "odatanext" {
+		requestString = endpoint
+		method = "GET"
 	}
 
-	req.Header.Add("Authorization", t.AccessToken.TokenType+" "+t.AccessToken.AccessToken)
-
-	if method == "POST" {
-		req.Header.Add("Content-Type", "application/json")
+	if method == "GET" && param != "" {
+		requestString = requestString + "?" + param
 	}
 
-	log.Printf("Calling %s \n", req.URL)
-
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Println(err)
-		return []byte{}, err
-	}
+	return t.sendRequest(method, requestString, param)
+}
 
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
+// callNewGraphAPIWithContent calls the Microsoft Graph API with a request body of the given content type, e.g. application/xml
+func (t Tenant) callNewGraphAPIWithContent(endpoint string, method string, contentType string, content []byte) ([]byte, error) {
+	return t.sendContent(method, t.graphURL()+endpoint, nil, contentType, content)
+}
 
-	if resp.StatusCode > 204 {
-		err = fmt.Errorf("Failed API call; status code: %s", resp.Status)
-		return bodyBytes, err
+// sendRequest sends the API request with param as JSON body for all methods but GET
+func (t Tenant) sendRequest(method string, requestString string, param string) ([]byte, error) {
+	if method != "GET" && param != "" {
+		return t.sendContent(method, requestString, nil, "application/json", []byte(param))
 	}
+	return t.sendContent(method, requestString, nil, "", nil)
+}
 
-	return bodyBytes, nil
+// sendContent sends the API request with the access token of t. The request waits for t.RateLimiter,
+// throttled (429) and, for idempotent methods, unavailable (503, 504) responses are retried up to t.MaxRetries times.
+// header contains additional request headers, e.g. ConsistencyLevel, and may be nil.
+func (t Tenant) sendContent(method string, requestString string, header http.Header, contentType string, content []byte) ([]byte, error) {
+	response, _, err := t.sendContentStatus(method, requestString, header, contentType, content)
+	return response, err
 }
 
-// CallNewGraphAPI does the API call to the Azure AD Graph API and returns the response as an APIRespone struct
-func (t Tenant) callNewGraphAPI(endpoint string, method string, param string) ([]byte, error) {
-	requestString := "https://graph.microsoft.com/beta" + endpoint
+// sendContentStatus works like sendContent and additionally returns the status code of successful responses
+func (t Tenant) sendContentStatus(method string, requestString string, header http.Header, contentType string, content []byte) ([]byte, int, error) {
+	client := &http.Client{}
 
-	// fmt.Println(requestString)
+	for attempt := 0; ; attempt++ {
+		var body io.Reader
+		if content != nil {
+			body = bytes.NewReader(content)
+		}
 
-	client := &http.Client{}
+		req, err := http.NewRequest(method, requestString, body)
+		if err != nil {
+			return []byte{}, 0, fmt.Errorf("error while creating request: %s", err)
+		}
 
-	if method == "odatanext" {
-		requestString = endpoint
-		method = "GET"
-	}
+		for name, values := range header {
+			for _, value := range values {
+				req.Header.Add(name, value)
+			}
+		}
 
-	if method == "GET" && param != "" {
-		requestString = requestString + "?" + param
-	}
+		req.Header.Add("Authorization", t.AccessToken.TokenType+" "+t.AccessToken.AccessToken)
 
-	req, err := http.NewRequest(method, requestString, nil)
+		if body != nil {
+			req.Header.Add("Content-Type", contentType)
+		}
 
-	if method == "POST" && param != "" {
-		postReader := strings.NewReader(param)
-		req, err = http.NewRequest(method, requestString, postReader)
-	}
+		t.RateLimiter.Wait()
 
-	if err != nil {
-		fmt.Println(err)
-		return []byte{}, err
-	}
+		log.Printf("Calling %s \n", req.URL)
 
-	req.Header.Add("Authorization", t.AccessToken.TokenType+" "+t.AccessToken.AccessToken)
+		resp, err := client.Do(req)
+		if err != nil {
+			return []byte{}, 0, fmt.Errorf("error while calling %s: %s", req.URL, err)
+		}
 
-	if method == "POST" {
-		req.Header.Add("Content-Type", "application/json")
-	}
+		bodyBytes, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
 
-	log.Printf("Calling %s \n", req.URL)
+		if isRetryable(method, resp.StatusCode) && attempt < t.MaxRetries {
+			delay := parseRetryAfter(resp.Header.Get("Retry-After"), time.Second<<uint(attempt))
+			log.Printf("Retrying %s in %s after %s", req.URL, delay, resp.Status)
+			time.Sleep(delay)
+			continue
+		}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Println(err)
-		return []byte{}, err
-	}
+		if resp.StatusCode > 204 {
+			return bodyBytes, resp.StatusCode, &APIError{StatusCode: resp.StatusCode, Status: resp.Status, Body: bodyBytes}
+		}
 
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
+		return bodyBytes, resp.StatusCode, nil
+	}
+}
 
-	if resp.StatusCode > 204 {
-		err = fmt.Errorf("Failed API call; status code: %s", resp.Status)
-		return bodyBytes, err
+// isRetryable returns true for responses that are worth retrying after a delay. Throttled requests were not
+// processed, but after a 503 or 504 the request may have been executed, so POST and PATCH are not retried then,
+// e.g. to not add a second client secret.
+func isRetryable(method string, statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return method == "GET" || method == "PUT" || method == "DELETE"
 	}
+	return false
+}
 
-	return bodyBytes, nil
+// parseRetryAfter returns the delay of a Retry-After header in seconds, or fallback if it is missing or invalid
+func parseRetryAfter(value string, fallback time.Duration) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return fallback
 }
 
 // GetUserDetails
 
 // GetAccessToken returns the access token for API access
+// If Credential, ManagedIdentity or FederatedTokenFile is set, the token is obtained from the v2.0 token endpoint,
+// otherwise from the v1 token endpoint.
 func (t *Tenant) GetAccessToken() error {
-
-	authAuthenticatorURL := loginURL + t.TenantDomain + "/oauth2/token?api-version=1.0"
-
-	parameters := url.Values{
-		"client_id":     {t.ClientID},
-		"client_secret": {t.ClientSecret},
-		"grant_type":    {"client_credentials"},
+	if t.Credential != nil || t.ManagedIdentity != nil || t.FederatedTokenFile != "" {
+		return t.useCredential(t.credential(), t.cloud().AADGraphURL)
 	}
 
-	resp, err := http.PostForm(authAuthenticatorURL, parameters)
-	if err != nil {
-		fmt.Printf("Error in POSTing the token request: %s\n", err)
-	}
-
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	authAuthenticatorURL := t.cloud().LoginURL + t.authority() + "/oauth2/token?api-version=1.0"
 
+	parameters, err := t.clientCredentials(authAuthenticatorURL)
 	if err != nil {
-		return fmt.Errorf("error in reading the response body: %s", err)
-	}
-
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("error while calling auth endpoint %s: %s", authAuthenticatorURL, string(bodyBytes))
+		return fmt.Errorf("error building client credentials: %s", err)
 	}
 
-	at := AccessToken{}
-
-	err = json.Unmarshal(bodyBytes, &at)
+	tok, err := postTokenRequest(context.Background(), authAuthenticatorURL, parameters)
 	if err != nil {
-		return fmt.Errorf("Error getting the Access token: %s", err)
+		return err
 	}
 
-	t.AccessToken = at
+	t.AccessToken = AccessToken{AccessToken: tok.AccessToken, TokenType: tok.TokenType}
 	return nil
 }
 
 // GetGraphAccessToken returns the access token for API access
 func (t *Tenant) GetGraphAccessToken() error {
+	return t.useCredential(t.credential(), t.cloud().GraphURL)
+}
 
-	authAuthenticatorURL := loginURL + t.TenantDomain + "/oauth2/v2.0/token"
-
+// clientCredentials returns the parameters that authenticate the app registration at the v1 token endpoint tokenURL.
+// The client certificate takes precedence over the client secret. Federated tokens are only accepted by the v2.0 endpoint.
+func (t Tenant) clientCredentials(tokenURL string) (url.Values, error) {
 	parameters := url.Values{
-		"client_id":     {t.ClientID},
-		"client_secret": {t.ClientSecret},
-		"grant_type":    {"client_credentials"},
-		"scope":         {"https://graph.microsoft.com/.default"},
+		"client_id":  {t.ClientID},
+		"grant_type": {"client_credentials"},
 	}
 
-	resp, err := http.PostForm(authAuthenticatorURL, parameters)
-	if err != nil {
-		return fmt.Errorf("Error in POSTing the token request: %s\n", err)
-	}
+	var assertion string
+	var err error
 
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	switch {
+	case t.ClientCertificate != nil:
+		assertion, err = t.ClientCertificate.ClientAssertion(t.ClientID, tokenURL)
+	default:
+		parameters.Set("client_secret", t.ClientSecret)
+		return parameters, nil
+	}
 
 	if err != nil {
-		return fmt.Errorf("error in reading the response body: %s", err)
+		return nil, err
 	}
 
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("error while calling auth endpoint %s: %s", authAuthenticatorURL, string(bodyBytes))
-	}
+	parameters.Set("client_assertion_type", clientAssertionType)
+	parameters.Set("client_assertion", assertion)
 
-	at := AccessToken{}
+	return parameters, nil
+}
 
-	err = json.Unmarshal(bodyBytes, &at)
-	if err != nil {
-		return fmt.Errorf("Error getting the Access token: %s", err)
+// authority returns the tenant identifier used in token requests
+func (t Tenant) authority() string {
+	if t.TenantID != "" {
+		return t.TenantID
 	}
-
-	t.AccessToken = at
-	return nil
+	return t.TenantDomain
 }
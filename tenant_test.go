@@ -1,15 +1,17 @@
 package tenant
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 )
 
 func TestGetAccessToken(t *testing.T) {
-	tn := Tenant{}
-	tn.ClientID = os.Getenv("B2C_CLIENT_ID")
-	tn.ClientSecret = os.Getenv("B2C_CLIENT_SECRET")
-	tn.TenantDomain = os.Getenv("B2C_TENANT_DOMAIN")
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
 
 	if err := tn.GetAccessToken(); err != nil {
 		t.Errorf("Error while obtaining access token: %s", err)
@@ -17,10 +19,10 @@ func TestGetAccessToken(t *testing.T) {
 }
 
 func TestGetGraphAccessToken(t *testing.T) {
-	tn := Tenant{}
-	tn.ClientID = os.Getenv("B2C_CLIENT_ID")
-	tn.ClientSecret = os.Getenv("B2C_CLIENT_SECRET")
-	tn.TenantDomain = os.Getenv("B2C_TENANT_DOMAIN")
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
 
 	if err := tn.GetGraphAccessToken(); err != nil {
 		t.Errorf("Error while obtaining access token: %s", err)
@@ -28,10 +30,10 @@ func TestGetGraphAccessToken(t *testing.T) {
 }
 
 func TestCallNewGraphAPI(t *testing.T) {
-	tn := Tenant{}
-	tn.ClientID = os.Getenv("B2C_CLIENT_ID")
-	tn.ClientSecret = os.Getenv("B2C_CLIENT_SECRET")
-	tn.TenantDomain = os.Getenv("B2C_TENANT_DOMAIN")
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
 
 	if err := tn.GetGraphAccessToken(); err != nil {
 		t.Errorf("Error while obtaining access token: %s", err)
@@ -39,17 +41,17 @@ func TestCallNewGraphAPI(t *testing.T) {
 
 	userObjectID := os.Getenv("B2C_TESTUSER")
 
-	_, err := tn.callNewGraphAPI("/users/"+userObjectID, "GET", "")
+	_, err = tn.callNewGraphAPI("/users/"+userObjectID, "GET", "")
 	if err != nil {
 		t.Errorf("Error while reading user: %s", err)
 	}
 }
 
 func TestCallGraphAPI(t *testing.T) {
-	tn := Tenant{}
-	tn.ClientID = os.Getenv("B2C_CLIENT_ID")
-	tn.ClientSecret = os.Getenv("B2C_CLIENT_SECRET")
-	tn.TenantDomain = os.Getenv("B2C_TENANT_DOMAIN")
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
 
 	if err := tn.GetAccessToken(); err != nil {
 		t.Errorf("Error while obtaining access token: %s", err)
@@ -57,8 +59,71 @@ func TestCallGraphAPI(t *testing.T) {
 
 	userObjectID := os.Getenv("B2C_TESTUSER")
 
-	_, err := tn.callGraphAPI("/users/"+userObjectID, "1.6", "GET", "")
+	_, err = tn.callGraphAPI("/users/"+userObjectID, "1.6", "GET", "")
 	if err != nil {
 		t.Errorf("Error while reading user: %s", err)
 	}
 }
+
+func TestSendRequestRetries(t *testing.T) {
+	calls := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	tn := Tenant{MaxRetries: 1}
+
+	if _, err := tn.callNewGraphAPI(server.URL, "odatanext", ""); err == nil {
+		t.Errorf("Expected error after exhausting retries")
+	}
+
+	calls = 0
+	tn.MaxRetries = 2
+
+	if _, err := tn.callNewGraphAPI(server.URL, "odatanext", ""); err != nil {
+		t.Errorf("Error while calling API: %s", err)
+	}
+
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls)
+	}
+}
+
+func TestSendRequestNoRetryAfterPOST(t *testing.T) {
+	calls := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusGatewayTimeout)
+	}))
+	defer server.Close()
+
+	tn := Tenant{MaxRetries: 2}
+
+	if _, err := tn.sendRequest("POST", server.URL, `{}`); err == nil {
+		t.Errorf("Expected error for gateway timeout")
+	}
+
+	if calls != 1 {
+		t.Errorf("Expected POST not to be retried after a gateway timeout, got %d calls", calls)
+	}
+
+	calls = 0
+
+	if _, err := tn.sendRequest("GET", server.URL, ""); err == nil {
+		t.Errorf("Expected error after exhausting retries")
+	}
+
+	if calls != 3 {
+		t.Errorf("Expected GET to be retried, got %d calls", calls)
+	}
+}
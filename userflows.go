@@ -0,0 +1,250 @@
+package tenant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// Types of B2C user flows
+const (
+	UserFlowSignUpOrSignIn = "signUpOrSignIn"
+	UserFlowSignUp         = "signUp"
+	UserFlowSignIn         = "signIn"
+	UserFlowPasswordReset  = "passwordReset"
+	UserFlowProfileUpdate  = "profileUpdate"
+	UserFlowResourceOwner  = "resourceOwner"
+)
+
+// Steps of a user flow an API connector can be configured for
+const (
+	APIConnectorPostFederationSignup    = "postFederationSignup"
+	APIConnectorPostAttributeCollection = "postAttributeCollection"
+	APIConnectorPreTokenIssuance        = "preTokenIssuance"
+)
+
+// UserFlow is a B2C user flow. B2C prefixes the ID with B2C_1_ on creation.
+type UserFlow struct {
+	ID                             string  `json:"id"`
+	UserFlowType                   string  `json:"userFlowType"`
+	UserFlowTypeVersion            float64 `json:"userFlowTypeVersion"`
+	IsLanguageCustomizationEnabled bool    `json:"isLanguageCustomizationEnabled"`
+	DefaultLanguageTag             string  `json:"defaultLanguageTag,omitempty"`
+}
+
+// IdentityProviderBase contains the properties all identity providers have in common
+type IdentityProviderBase struct {
+	ODataType   string `json:"@odata.type,omitempty"`
+	ID          string `json:"id,omitempty"`
+	DisplayName string `json:"displayName"`
+}
+
+// UserFlowAttributeRef references a built-in or custom user attribute by its ID, e.g. "city" or "extension_<appId>_ShoeSize"
+type UserFlowAttributeRef struct {
+	ID string `json:"id"`
+}
+
+// UserAttributeValue is a selectable value of an attribute with a radio button, checkbox or dropdown input
+type UserAttributeValue struct {
+	Name      string `json:"name"`
+	Value     string `json:"value"`
+	IsDefault bool   `json:"isDefault"`
+}
+
+// UserAttributeAssignment adds an attribute to the attribute collection page of a user flow
+type UserAttributeAssignment struct {
+	ID                   string               `json:"id,omitempty"`
+	DisplayName          string               `json:"displayName"`
+	IsOptional           bool                 `json:"isOptional"`
+	RequiresVerification bool                 `json:"requiresVerification"`
+	UserInputType        string               `json:"userInputType"`
+	UserAttributeValues  []UserAttributeValue `json:"userAttributeValues"`
+	UserAttribute        UserFlowAttributeRef `json:"userAttribute"`
+}
+
+// APIConnector is an API connector that can be called by user flows
+type APIConnector struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+	TargetURL   string `json:"targetUrl"`
+}
+
+// APIConnectorConfiguration contains the API connectors of a user flow, nil for steps without a connector
+type APIConnectorConfiguration struct {
+	PostFederationSignup    *APIConnector `json:"postFederationSignup"`
+	PostAttributeCollection *APIConnector `json:"postAttributeCollection"`
+	PreTokenIssuance        *APIConnector `json:"preTokenIssuance"`
+}
+
+// UserFlowLanguage is a language of a user flow with language customization enabled
+type UserFlowLanguage struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+	IsEnabled   bool   `json:"isEnabled"`
+}
+
+// ListUserFlows returns all user flows of the tenant
+func (t Tenant) ListUserFlows() ([]UserFlow, error) {
+	userFlows := []UserFlow{}
+	err := t.listPages("/identity/b2cUserFlows", "", func(response []byte) (string, error) {
+		page := struct {
+			Value     []UserFlow `json:"value"`
+			ODataNext string     `json:"@odata.nextLink"`
+		}{}
+		if err := json.Unmarshal(response, &page); err != nil {
+			return "", fmt.Errorf("error unmarshaling JSON response: %s", err)
+		}
+		userFlows = append(userFlows, page.Value...)
+		return page.ODataNext, nil
+	})
+	return userFlows, err
+}
+
+// GetUserFlow returns the user flow with the given ID, e.g. B2C_1_signup_signin
+func (t Tenant) GetUserFlow(userFlowID string) (UserFlow, error) {
+	userFlow := UserFlow{}
+	err := t.getObject(userFlowPath(userFlowID), "", userFlowID, &userFlow)
+	return userFlow, err
+}
+
+// CreateUserFlow creates a user flow, the returned user flow contains the ID with the B2C_1_ prefix
+func (t Tenant) CreateUserFlow(userFlow UserFlow) (UserFlow, error) {
+	created := UserFlow{}
+
+	if userFlow.ID == "" || userFlow.UserFlowType == "" {
+		return created, errors.New("user flow ID and type are required")
+	}
+
+	err := t.postObject("/identity/b2cUserFlows", userFlow, &created)
+	return created, err
+}
+
+// UpdateUserFlow changes the language settings of a user flow, the other properties can't be changed
+func (t Tenant) UpdateUserFlow(userFlowID string, isLanguageCustomizationEnabled bool, defaultLanguageTag string) error {
+	payload := map[string]interface{}{"isLanguageCustomizationEnabled": isLanguageCustomizationEnabled}
+	if defaultLanguageTag != "" {
+		payload["defaultLanguageTag"] = defaultLanguageTag
+	}
+	return t.sendObject(userFlowPath(userFlowID), "PATCH", "updating", userFlowID, payload)
+}
+
+// DeleteUserFlow deletes the user flow
+func (t Tenant) DeleteUserFlow(userFlowID string) error {
+	return t.sendObject(userFlowPath(userFlowID), "DELETE", "deleting", userFlowID, nil)
+}
+
+// GetUserFlowIdentityProviders returns the identity providers users can sign in with in the user flow
+func (t Tenant) GetUserFlowIdentityProviders(userFlowID string) ([]IdentityProviderBase, error) {
+	page := struct {
+		Value []IdentityProviderBase `json:"value"`
+	}{}
+	err := t.getObject(userFlowPath(userFlowID)+"/userFlowIdentityProviders", "", userFlowID, &page)
+	return page.Value, err
+}
+
+// AddUserFlowIdentityProvider adds an identity provider to the user flow
+func (t Tenant) AddUserFlowIdentityProvider(userFlowID string, identityProviderID string) error {
+	ref := map[string]string{"@odata.id": t.graphURL() + identityProviderPath(identityProviderID)}
+	return t.sendObject(userFlowPath(userFlowID)+"/userFlowIdentityProviders/$ref", "POST", "adding an identity provider to", userFlowID, ref)
+}
+
+// RemoveUserFlowIdentityProvider removes an identity provider from the user flow
+func (t Tenant) RemoveUserFlowIdentityProvider(userFlowID string, identityProviderID string) error {
+	return t.sendObject(userFlowPath(userFlowID)+"/userFlowIdentityProviders/"+url.PathEscape(identityProviderID)+"/$ref", "DELETE", "removing an identity provider from", userFlowID, nil)
+}
+
+// GetUserAttributeAssignments returns the attributes collected by the user flow in the order they are shown
+func (t Tenant) GetUserAttributeAssignments(userFlowID string) ([]UserAttributeAssignment, error) {
+	page := struct {
+		Value []UserAttributeAssignment `json:"value"`
+	}{}
+	err := t.getObject(userFlowPath(userFlowID)+"/userAttributeAssignments", "", userFlowID, &page)
+	return page.Value, err
+}
+
+// AddUserAttributeAssignment adds an attribute to the attribute collection page of the user flow
+func (t Tenant) AddUserAttributeAssignment(userFlowID string, assignment UserAttributeAssignment) (UserAttributeAssignment, error) {
+	created := UserAttributeAssignment{}
+	err := t.postObject(userFlowPath(userFlowID)+"/userAttributeAssignments", assignment, &created)
+	return created, err
+}
+
+// DeleteUserAttributeAssignment removes an attribute from the attribute collection page of the user flow
+func (t Tenant) DeleteUserAttributeAssignment(userFlowID string, assignmentID string) error {
+	return t.sendObject(userFlowPath(userFlowID)+"/userAttributeAssignments/"+url.PathEscape(assignmentID), "DELETE", "removing an attribute from", userFlowID, nil)
+}
+
+// SetUserAttributeOrder sets the order of the attributes on the attribute collection page by their attribute IDs
+func (t Tenant) SetUserAttributeOrder(userFlowID string, attributeIDs []string) error {
+	refs := make([]UserFlowAttributeRef, len(attributeIDs))
+	for i, id := range attributeIDs {
+		refs[i] = UserFlowAttributeRef{ID: id}
+	}
+	return t.sendObject(userFlowPath(userFlowID)+"/userAttributeAssignments/setOrder", "POST", "ordering the attributes of", userFlowID, map[string]interface{}{"inputAttributes": refs})
+}
+
+// GetAPIConnectorConfiguration returns the API connectors called by the user flow
+func (t Tenant) GetAPIConnectorConfiguration(userFlowID string) (APIConnectorConfiguration, error) {
+	expand := "apiConnectorConfiguration/" + APIConnectorPostFederationSignup +
+		",apiConnectorConfiguration/" + APIConnectorPostAttributeCollection +
+		",apiConnectorConfiguration/" + APIConnectorPreTokenIssuance
+
+	userFlow := struct {
+		APIConnectorConfiguration APIConnectorConfiguration `json:"apiConnectorConfiguration"`
+	}{}
+	err := t.getObject(userFlowPath(userFlowID), "$expand="+url.QueryEscape(expand), userFlowID, &userFlow)
+	return userFlow.APIConnectorConfiguration, err
+}
+
+// SetAPIConnector calls the API connector in the given step of the user flow, e.g. APIConnectorPostAttributeCollection
+func (t Tenant) SetAPIConnector(userFlowID string, step string, apiConnectorID string) error {
+	ref := map[string]string{"@odata.id": t.graphURL() + "/identity/apiConnectors/" + url.PathEscape(apiConnectorID)}
+	return t.sendObject(userFlowPath(userFlowID)+"/apiConnectorConfiguration/"+step+"/$ref", "PUT", "setting an API connector of", userFlowID, ref)
+}
+
+// RemoveAPIConnector stops calling an API connector in the given step of the user flow
+func (t Tenant) RemoveAPIConnector(userFlowID string, step string) error {
+	return t.sendObject(userFlowPath(userFlowID)+"/apiConnectorConfiguration/"+step+"/$ref", "DELETE", "removing an API connector from", userFlowID, nil)
+}
+
+// GetUserFlowLanguages returns the languages of the user flow
+func (t Tenant) GetUserFlowLanguages(userFlowID string) ([]UserFlowLanguage, error) {
+	page := struct {
+		Value []UserFlowLanguage `json:"value"`
+	}{}
+	err := t.getObject(userFlowPath(userFlowID)+"/languages", "", userFlowID, &page)
+	return page.Value, err
+}
+
+// SetUserFlowLanguage enables or disables a language of the user flow, e.g. "de"
+func (t Tenant) SetUserFlowLanguage(userFlowID string, languageTag string, enabled bool) error {
+	return t.sendObject(languagePath(userFlowID, languageTag), "PUT", "updating a language of", userFlowID, map[string]bool{"isEnabled": enabled})
+}
+
+// GetLanguageOverrides returns the customized strings of a page of the user flow in a language, e.g. for the page "api.selfasserted"
+func (t Tenant) GetLanguageOverrides(userFlowID string, languageTag string, pageID string) ([]byte, error) {
+	response, err := t.callNewGraphAPI(languagePath(userFlowID, languageTag)+"/overridesPages/"+url.PathEscape(pageID)+"/$value", "GET", "")
+	if err != nil {
+		return nil, fmt.Errorf("error while reading %s overrides of %s: %s", languageTag, userFlowID, err)
+	}
+	return response, nil
+}
+
+// SetLanguageOverrides replaces the customized strings of a page of the user flow in a language.
+// overrides is the localized resources JSON as returned by GetLanguageOverrides.
+func (t Tenant) SetLanguageOverrides(userFlowID string, languageTag string, pageID string, overrides []byte) error {
+	response, err := t.callNewGraphAPIWithContent(languagePath(userFlowID, languageTag)+"/overridesPages/"+url.PathEscape(pageID)+"/$value", "PUT", "application/json", overrides)
+	if err != nil {
+		return fmt.Errorf("error while setting %s overrides of %s: %s\n%s", languageTag, userFlowID, err, string(response))
+	}
+	return nil
+}
+
+func userFlowPath(userFlowID string) string {
+	return "/identity/b2cUserFlows/" + url.PathEscape(userFlowID)
+}
+
+func languagePath(userFlowID string, languageTag string) string {
+	return userFlowPath(userFlowID) + "/languages/" + url.PathEscape(languageTag)
+}
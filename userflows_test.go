@@ -0,0 +1,76 @@
+package tenant
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIConnectorConfiguration(t *testing.T) {
+	var method, path, expand string
+	var ref map[string]string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, expand = r.Method, r.URL.Path, r.URL.Query().Get("$expand")
+		if r.Method == "PUT" {
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &ref)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(`{"id": "B2C_1_signup", "apiConnectorConfiguration": {"postAttributeCollection": {"id": "c1", "targetUrl": "https://example.com/connector"}}}`))
+	}))
+	defer server.Close()
+
+	tn := Tenant{Cloud: Cloud{LoginURL: server.URL + "/", GraphURL: server.URL + "/"}}
+
+	if err := tn.SetAPIConnector("B2C_1_signup", APIConnectorPostAttributeCollection, "c1"); err != nil {
+		t.Fatalf("Error while setting API connector: %s", err)
+	}
+
+	if method != "PUT" || path != "/beta/identity/b2cUserFlows/B2C_1_signup/apiConnectorConfiguration/postAttributeCollection/$ref" || ref["@odata.id"] != server.URL+"/beta/identity/apiConnectors/c1" {
+		t.Errorf("Unexpected request: %s %s %v", method, path, ref)
+	}
+
+	// IDs are escaped, so they can't point to other objects
+	if err := tn.SetAPIConnector("B2C_1_signup", APIConnectorPostAttributeCollection, "../c1"); err != nil {
+		t.Fatalf("Error while setting API connector: %s", err)
+	}
+
+	if ref["@odata.id"] != server.URL+"/beta/identity/apiConnectors/..%2Fc1" {
+		t.Errorf("Unexpected reference %v", ref)
+	}
+
+	config, err := tn.GetAPIConnectorConfiguration("B2C_1_signup")
+	if err != nil {
+		t.Fatalf("Error while reading API connector configuration: %s", err)
+	}
+
+	if expand == "" || config.PostAttributeCollection == nil || config.PostAttributeCollection.ID != "c1" || config.PostFederationSignup != nil {
+		t.Errorf("Unexpected configuration %+v with $expand=%s", config, expand)
+	}
+}
+
+func TestListUserFlows(t *testing.T) {
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
+
+	if err := tn.GetGraphAccessToken(); err != nil {
+		t.Errorf("Error while obtaining access token: %s", err)
+	}
+
+	userFlows, err := tn.ListUserFlows()
+	if err != nil {
+		t.Fatalf("Error while listing user flows: %s", err)
+	}
+
+	for _, userFlow := range userFlows {
+		if _, err := tn.GetUserAttributeAssignments(userFlow.ID); err != nil {
+			t.Errorf("Error while reading attributes of %s: %s", userFlow.ID, err)
+		}
+	}
+}
@@ -2,11 +2,17 @@ package tenant
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"strings"
+	"sync"
 )
 
+// groupLookupWorkers is the maximum number of concurrent requests used for resolving group details
+const groupLookupWorkers = 8
+
 // The MemberGroupIdsResponse struct contains the list of group objectIds the queried user is part of
 type MemberGroupIdsResponse struct {
 	GroupIds []string `json:"value"`
@@ -18,22 +24,36 @@ type UserResponse struct {
 	ODataNext string `json:"@odata.nextLink"`
 }
 
+// ODataUser is the @odata.type of users, e.g. to tell them apart from other group members
+const ODataUser = "#microsoft.graph.user"
+
 // The User struct contains the details from the B2C users
 type User struct {
 	ObjectID       string   `json:"objectId"`
 	DisplayName    string   `json:"displayName"`
 	EmailAddresses []string `json:"otherMails"`
 	ID             string   `json:"id"`
+	// ODataType is only set by Microsoft Graph, GetGroupMembers also returns groups and service principals
+	ODataType string `json:"@odata.type,omitempty"`
 }
 
 // GetMemberGroupIDs returns a list of group objectIds the user is part of
-// This is for the /getMemberGroups/ handler that is used by the B2C custom policy
+// This is for the /getMemberGroups/ handler that is used by the B2C custom policy, see restapi.GroupsHandler
 func (t Tenant) GetMemberGroupIDs(UserObjectID string) ([]string, error) {
+	cacheKey := memberGroupsCacheKey + UserObjectID
+
+	if cached, ok, err := t.cacheGet(cacheKey); ok {
+		if err != nil {
+			return nil, fmt.Errorf("error calling Graph API: %s", err)
+		}
+		return append([]string{}, cached.([]string)...), nil
+	}
 
 	parameter := "{\"securityEnabledOnly\": false}"
 
 	ar, err := t.callGraphAPI("/users/"+UserObjectID+"/getMemberGroups", "1.6", "POST", parameter)
 	if err != nil {
+		t.cacheSetError(cacheKey, err)
 		return nil, fmt.Errorf("error calling Graph API: %s", err)
 	}
 
@@ -41,41 +61,69 @@ func (t Tenant) GetMemberGroupIDs(UserObjectID string) ([]string, error) {
 
 	err = json.Unmarshal(ar, &mgr)
 	if err != nil {
-		fmt.Println(err)
+		return nil, fmt.Errorf("error unmarshaling JSON response: %s", err)
 	}
 
+	t.cacheSet(cacheKey, append([]string{}, mgr.GroupIds...), t.cacheTTLs().MemberGroups)
+
 	return mgr.GroupIds, nil
 }
 
-// GetMemberGroupsDetailed returns a list of group objectIds the user is part of
+// GetMemberGroupsDetailed returns the display names of the groups the user is part of, see GetGroupNames
 func (t Tenant) GetMemberGroupsDetailed(UserObjectID string) ([]string, error) {
-
-	parameter := "{\"securityEnabledOnly\": false}"
-
-	ar, err := t.callGraphAPI("/users/"+UserObjectID+"/getMemberGroups", "1.6", "POST", parameter)
+	groupIDs, err := t.GetMemberGroupIDs(UserObjectID)
 	if err != nil {
-		msg := "Error in calling API: " + err.Error()
-		log.Println(msg)
+		return nil, err
 	}
 
-	mgr := MemberGroupIdsResponse{}
+	return t.GetGroupNames(groupIDs)
+}
 
-	err = json.Unmarshal(ar, &mgr)
-	if err != nil {
-		fmt.Println(err)
+// GetGroupNames returns the display names of the groups with the given objectIds.
+// The group names are resolved concurrently by at most groupLookupWorkers requests at a time and
+// are cached in t.Cache if it is set.
+// If some of the groups can't be resolved, the names of all other groups are returned along with an error.
+func (t Tenant) GetGroupNames(groupIDs []string) ([]string, error) {
+	groups := make([]Group, len(groupIDs))
+	errs := make([]error, len(groupIDs))
+
+	// Fetching Details of user group, the indexes are handed to a fixed number of workers
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < groupLookupWorkers && w < len(groupIDs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				groups[i], errs[i] = t.GetGroup(groupIDs[i])
+			}
+		}()
 	}
 
-	// Fetching Details of user group
-	groups := make([]string, len(mgr.GroupIds))
-	for i, groupID := range mgr.GroupIds {
-		group, err := t.GetGroup(groupID)
-		if err != nil {
-			log.Println(err)
+	for i := range groupIDs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	names := make([]string, 0, len(groups))
+	failed := []string{}
+
+	for i, group := range groups {
+		if errs[i] != nil {
+			log.Println(errs[i])
+			failed = append(failed, groupIDs[i])
+			continue
 		}
-		groups[i] = group.DisplayName
+		names = append(names, group.DisplayName)
+	}
+
+	if len(failed) > 0 {
+		return names, fmt.Errorf("error while reading %d of %d groups: %s", len(failed), len(groupIDs), strings.Join(failed, ", "))
 	}
 
-	return groups, nil
+	return names, nil
 }
 
 // GetUsers returns a list of users in the B2C directory
@@ -100,11 +148,21 @@ func (t Tenant) GetUsers() ([]User, error) {
 
 // GetUser returns a single user's details from the B2C directory
 func (t Tenant) GetUser(objectID string) (User, error) {
+	cacheKey := userCacheKey + objectID
+
+	if cached, ok, err := t.cacheGet(cacheKey); ok {
+		if err != nil {
+			return User{}, fmt.Errorf("Error in calling API: %s", err)
+		}
+		return cached.(User), nil
+	}
+
 	ar, err := t.callNewGraphAPI("/users/"+objectID, "GET", "")
 	if err != nil {
+		t.cacheSetError(cacheKey, err)
 		msg := "Error in calling API: " + err.Error()
 		log.Println(msg)
-		return User{}, fmt.Errorf(msg)
+		return User{}, errors.New(msg)
 	}
 
 	ur := User{}
@@ -114,6 +172,8 @@ func (t Tenant) GetUser(objectID string) (User, error) {
 		fmt.Println(err)
 	}
 
+	t.cacheSet(cacheKey, ur, t.cacheTTLs().User)
+
 	return ur, nil
 }
 
@@ -158,3 +218,22 @@ func (t Tenant) SearchUser(userEmail string) ([]User, error) {
 
 	return foundUsers, nil
 }
+
+// GetUsersByEmail returns all users that have the supplied address in their otherMails attribute
+func (t Tenant) GetUsersByEmail(userEmail string) ([]User, error) {
+	encodedFilter := url.QueryEscape("otherMails/any(x:x eq " + odataString(userEmail) + ")")
+
+	response, err := t.callNewGraphAPI("/users", "GET", "$filter="+encodedFilter)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading user: %s", err)
+	}
+
+	ur := UserResponse{}
+
+	err = json.Unmarshal(response, &ur)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshaling JSON response: %s", err)
+	}
+
+	return ur.Users, nil
+}
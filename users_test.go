@@ -7,10 +7,10 @@ import (
 )
 
 func TestGetUser(t *testing.T) {
-	tn := Tenant{}
-	tn.ClientID = os.Getenv("B2C_CLIENT_ID")
-	tn.ClientSecret = os.Getenv("B2C_CLIENT_SECRET")
-	tn.TenantDomain = os.Getenv("B2C_TENANT_DOMAIN")
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
 
 	if err := tn.GetGraphAccessToken(); err != nil {
 		t.Errorf("Error while obtaining access token: %s", err)
@@ -30,10 +30,10 @@ func TestGetUser(t *testing.T) {
 }
 
 func TestSearchUser(t *testing.T) {
-	tn := Tenant{}
-	tn.ClientID = os.Getenv("B2C_CLIENT_ID")
-	tn.ClientSecret = os.Getenv("B2C_CLIENT_SECRET")
-	tn.TenantDomain = os.Getenv("B2C_TENANT_DOMAIN")
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
 
 	if err := tn.GetGraphAccessToken(); err != nil {
 		t.Errorf("Error while obtaining access token: %s", err)
@@ -55,10 +55,10 @@ func TestSearchUser(t *testing.T) {
 }
 
 func TestGetMemberGroupIDs(t *testing.T) {
-	tn := Tenant{}
-	tn.ClientID = os.Getenv("B2C_CLIENT_ID")
-	tn.ClientSecret = os.Getenv("B2C_CLIENT_SECRET")
-	tn.TenantDomain = os.Getenv("B2C_TENANT_DOMAIN")
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
 
 	if err := tn.GetAccessToken(); err != nil {
 		t.Errorf("Error while obtaining access token: %s", err)
@@ -78,3 +78,37 @@ func TestGetMemberGroupIDs(t *testing.T) {
 		t.Errorf("Expected groups %q, got %q", expectedGroups, gotGroups)
 	}
 }
+
+func TestGetMemberGroupsDetailed(t *testing.T) {
+	tn, err := NewTenantFromEnv()
+	if err != nil {
+		t.Fatalf("Error while reading tenant configuration: %s", err)
+	}
+	tn.Cache = NewLRUCache(100)
+
+	if err := tn.GetAccessToken(); err != nil {
+		t.Errorf("Error while obtaining access token: %s", err)
+	}
+
+	userObjectID := os.Getenv("B2C_TESTUSER")
+
+	groups, err := tn.GetMemberGroupsDetailed(userObjectID)
+	if err != nil {
+		t.Errorf("Error while obtaining member group details: %s", err)
+	}
+
+	expectedGroups := strings.Fields(os.Getenv("B2C_USERGROUPS"))
+	if len(groups) != len(expectedGroups) {
+		t.Errorf("Expected %d groups, got %d: %q", len(expectedGroups), len(groups), groups)
+	}
+
+	// the second call has to be served from the cache and return the same names
+	cachedGroups, err := tn.GetMemberGroupsDetailed(userObjectID)
+	if err != nil {
+		t.Errorf("Error while obtaining cached member group details: %s", err)
+	}
+
+	if strings.Join(groups, " ") != strings.Join(cachedGroups, " ") {
+		t.Errorf("Expected cached groups %q, got %q", groups, cachedGroups)
+	}
+}
@@ -0,0 +1,393 @@
+// Package validator validates ID and access tokens issued by the user flows and custom policies of an Azure AD B2C tenant
+package validator
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultRefreshInterval is how long the signing keys are used before they are fetched again
+const defaultRefreshInterval = 24 * time.Hour
+
+// minRefreshInterval limits how often an unknown key ID can trigger fetching the keys again
+const minRefreshInterval = 5 * time.Minute
+
+// failedRefreshBackoff is how long no new fetch is started after fetching the keys failed
+const failedRefreshBackoff = 30 * time.Second
+
+// defaultClockSkew is the tolerance for exp and nbf
+const defaultClockSkew = 2 * time.Minute
+
+// defaultHTTPTimeout is the timeout of the default HTTP client for the metadata and keys
+const defaultHTTPTimeout = 10 * time.Second
+
+// Config contains the tenant, policy and audience the tokens are validated against
+type Config struct {
+	// TenantDomain is the domain of the B2C tenant, e.g. example.onmicrosoft.com
+	TenantDomain string
+	// Policy is the user flow or custom policy that issued the tokens, e.g. B2C_1_signupsignin
+	Policy string
+	// Audiences are the accepted aud claims, usually the application (client) ID
+	Audiences []string
+	// MetadataURL overrides the OpenID configuration URL derived from TenantDomain and Policy, e.g. for testing
+	MetadataURL string
+	// RefreshInterval is how long the signing keys are cached, defaults to 24 hours
+	RefreshInterval time.Duration
+	// ClockSkew is the tolerance when checking exp and nbf, defaults to 2 minutes
+	ClockSkew time.Duration
+	// HTTPClient is used to fetch the metadata and keys, defaults to a client with a 10 second timeout
+	HTTPClient *http.Client
+}
+
+// Claims contains the validated claims of a token
+type Claims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  Audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	IssuedAt  int64    `json:"iat"`
+	Nonce     string   `json:"nonce"`
+	// TFP is the policy of tokens issued by custom policies and newer user flows, ACR the one of older user flows
+	TFP      string   `json:"tfp"`
+	ACR      string   `json:"acr"`
+	ObjectID string   `json:"oid"`
+	Name     string   `json:"name"`
+	Emails   []string `json:"emails"`
+	Scope    string   `json:"scp"`
+	// Raw contains all claims, including custom ones
+	Raw map[string]interface{} `json:"-"`
+}
+
+// Audience is the aud claim, which can be a single string or a list of strings
+type Audience []string
+
+// UnmarshalJSON accepts a string as well as an array of strings
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return fmt.Errorf("aud is neither a string nor a list of strings")
+	}
+	*a = multiple
+	return nil
+}
+
+// Policy returns the policy that issued the token, from tfp or acr
+func (c Claims) Policy() string {
+	if c.TFP != "" {
+		return c.TFP
+	}
+	return c.ACR
+}
+
+// Validator validates tokens of one tenant and policy. It fetches and caches the OpenID configuration
+// and signing keys, and fetches them again when they expire or a token is signed with an unknown key.
+// It is safe for concurrent use.
+type Validator struct {
+	config Config
+
+	mu      sync.Mutex
+	issuer  string
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+	// failed and err are the time and error of the last failed fetch
+	failed time.Time
+	err    error
+	// refreshing is closed when the running fetch is done, nil if none is running
+	refreshing chan struct{}
+}
+
+type openIDConfiguration struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	KeyID string `json:"kid"`
+	Type  string `json:"kty"`
+	N     string `json:"n"`
+	E     string `json:"e"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+type tokenHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// New returns a Validator for the given Config, at least one audience is required
+func New(c Config) (*Validator, error) {
+	if len(c.Audiences) == 0 {
+		return nil, fmt.Errorf("no audiences configured, tokens of any application would be accepted")
+	}
+	if c.RefreshInterval == 0 {
+		c.RefreshInterval = defaultRefreshInterval
+	}
+	if c.ClockSkew == 0 {
+		c.ClockSkew = defaultClockSkew
+	}
+	if c.HTTPClient == nil {
+		c.HTTPClient = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+	if c.MetadataURL == "" {
+		tenantName := strings.SplitN(c.TenantDomain, ".", 2)[0]
+		c.MetadataURL = "https://" + tenantName + ".b2clogin.com/" + c.TenantDomain + "/" + c.Policy + "/v2.0/.well-known/openid-configuration"
+	}
+
+	return &Validator{config: c}, nil
+}
+
+// Validate checks the signature, issuer, audience, expiry and policy of token and returns its claims
+func (v *Validator) Validate(token string) (*Claims, error) {
+	return v.validate(token, "")
+}
+
+// ValidateWithNonce validates token like Validate and additionally checks that its nonce claim matches nonce
+func (v *Validator) ValidateWithNonce(token, nonce string) (*Claims, error) {
+	if nonce == "" {
+		return nil, fmt.Errorf("no nonce specified")
+	}
+	return v.validate(token, nonce)
+}
+
+func (v *Validator) validate(token, nonce string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("token is not a JWT")
+	}
+
+	header := tokenHeader{}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("error decoding token header: %s", err)
+	}
+
+	if header.Algorithm != "RS256" {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", header.Algorithm)
+	}
+
+	key, issuer, err := v.key(header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("error decoding token signature: %s", err)
+	}
+
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature); err != nil {
+		return nil, fmt.Errorf("invalid token signature")
+	}
+
+	claims := &Claims{}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, fmt.Errorf("error decoding token claims: %s", err)
+	}
+	if err := decodeSegment(parts[1], &claims.Raw); err != nil {
+		return nil, fmt.Errorf("error decoding token claims: %s", err)
+	}
+
+	if err := v.checkClaims(claims, issuer, nonce); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// checkClaims verifies the registered and B2C specific claims of a token with a valid signature
+func (v *Validator) checkClaims(claims *Claims, issuer, nonce string) error {
+	now := time.Now()
+
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(v.config.ClockSkew)) {
+		return fmt.Errorf("token is expired")
+	}
+
+	if claims.NotBefore != 0 && now.Add(v.config.ClockSkew).Before(time.Unix(claims.NotBefore, 0)) {
+		return fmt.Errorf("token is not valid yet")
+	}
+
+	if claims.Issuer != issuer {
+		return fmt.Errorf("invalid issuer %q", claims.Issuer)
+	}
+
+	if !v.validAudience(claims.Audience) {
+		return fmt.Errorf("invalid audience %q", strings.Join(claims.Audience, ", "))
+	}
+
+	if v.config.Policy != "" && !strings.EqualFold(claims.Policy(), v.config.Policy) {
+		return fmt.Errorf("token was issued by policy %q, expected %q", claims.Policy(), v.config.Policy)
+	}
+
+	if nonce != "" && claims.Nonce != nonce {
+		return fmt.Errorf("invalid nonce")
+	}
+
+	return nil
+}
+
+func (v *Validator) validAudience(audience Audience) bool {
+	for _, aud := range audience {
+		for _, accepted := range v.config.Audiences {
+			if aud == accepted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// key returns the signing key with the given ID and the issuer, fetching the metadata if needed
+func (v *Validator) key(keyID string) (*rsa.PublicKey, string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key, ok := v.keys[keyID]
+
+	if v.needsRefresh(ok) {
+		v.refresh()
+		key, ok = v.keys[keyID]
+	}
+
+	// keep using the cached keys if the metadata endpoint is temporarily unavailable
+	if v.keys == nil && v.err != nil {
+		return nil, "", v.err
+	}
+
+	if !ok {
+		return nil, "", fmt.Errorf("unknown signing key %q", keyID)
+	}
+
+	return key, v.issuer, nil
+}
+
+// needsRefresh returns true if the keys should be fetched, known tells if the key ID of the token is cached. v.mu must be held.
+func (v *Validator) needsRefresh(known bool) bool {
+	if time.Since(v.failed) < failedRefreshBackoff {
+		return false
+	}
+
+	if time.Since(v.fetched) > v.config.RefreshInterval {
+		return true
+	}
+
+	// an unknown key ID means the keys were rotated, but don't let every forged token trigger a fetch
+	return !known && time.Since(v.fetched) > minRefreshInterval
+}
+
+// refresh fetches the OpenID configuration and the signing keys. v.mu must be held, it is released while fetching,
+// so validating tokens with cached keys isn't blocked. Concurrent callers wait for the running fetch instead of starting another one.
+func (v *Validator) refresh() {
+	if v.refreshing != nil {
+		done := v.refreshing
+		v.mu.Unlock()
+		<-done
+		v.mu.Lock()
+		return
+	}
+
+	done := make(chan struct{})
+	v.refreshing = done
+	v.mu.Unlock()
+
+	issuer, keys, err := v.fetch()
+
+	v.mu.Lock()
+	v.refreshing = nil
+	close(done)
+
+	if err != nil {
+		v.failed = time.Now()
+		v.err = err
+		return
+	}
+
+	v.issuer = issuer
+	v.keys = keys
+	v.fetched = time.Now()
+	v.failed = time.Time{}
+	v.err = nil
+}
+
+// fetch returns the issuer and the RSA signing keys of the OpenID configuration
+func (v *Validator) fetch() (string, map[string]*rsa.PublicKey, error) {
+	oc := openIDConfiguration{}
+	if err := v.getJSON(v.config.MetadataURL, &oc); err != nil {
+		return "", nil, fmt.Errorf("error fetching OpenID configuration: %s", err)
+	}
+
+	jwks := jsonWebKeySet{}
+	if err := v.getJSON(oc.JWKSURI, &jwks); err != nil {
+		return "", nil, fmt.Errorf("error fetching signing keys: %s", err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Type != "RSA" {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid modulus of key %s: %s", jwk.KeyID, err)
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid exponent of key %s: %s", jwk.KeyID, err)
+		}
+
+		keys[jwk.KeyID] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return oc.Issuer, keys, nil
+}
+
+func (v *Validator) getJSON(url string, target interface{}) error {
+	resp, err := v.config.HTTPClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+
+	return json.Unmarshal(bodyBytes, target)
+}
+
+func decodeSegment(segment string, target interface{}) error {
+	segmentBytes, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(segmentBytes, target)
+}
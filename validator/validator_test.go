@@ -0,0 +1,211 @@
+package validator
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testIssuer serves the OpenID configuration and keys of a fake B2C tenant and signs tokens
+type testIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	keyID  string
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+
+	ti := &testIssuer{key: key, keyID: "key1"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   ti.server.URL + "/v2.0/",
+			"jwks_uri": ti.server.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": ti.keyID,
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(ti.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(ti.key.E)).Bytes()),
+			}},
+		})
+	})
+	ti.server = httptest.NewServer(mux)
+
+	return ti
+}
+
+func (ti *testIssuer) token(t *testing.T, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": ti.keyID})
+	payload, _ := json.Marshal(claims)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(signingInput))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, ti.key, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatalf("Error signing token: %s", err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (ti *testIssuer) claims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":    ti.server.URL + "/v2.0/",
+		"aud":    "client-id",
+		"sub":    "user",
+		"oid":    "user",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"nbf":    time.Now().Unix(),
+		"tfp":    "B2C_1A_signup_signin",
+		"nonce":  "nonce",
+		"emails": []string{"user@example.com"},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	ti := newTestIssuer(t)
+	defer ti.server.Close()
+
+	v, err := New(Config{
+		Policy:      "B2C_1A_SIGNUP_SIGNIN",
+		Audiences:   []string{"client-id"},
+		MetadataURL: ti.server.URL + "/.well-known/openid-configuration",
+	})
+	if err != nil {
+		t.Fatalf("Error creating validator: %s", err)
+	}
+
+	claims, err := v.ValidateWithNonce(ti.token(t, ti.claims()), "nonce")
+	if err != nil {
+		t.Fatalf("Error validating token: %s", err)
+	}
+
+	if claims.ObjectID != "user" || len(claims.Emails) != 1 || claims.Raw["sub"] != "user" {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+
+	invalid := map[string]func(map[string]interface{}){
+		"expired":      func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"not yet":      func(c map[string]interface{}) { c["nbf"] = time.Now().Add(time.Hour).Unix() },
+		"issuer":       func(c map[string]interface{}) { c["iss"] = "https://evil.example.com/" },
+		"audience":     func(c map[string]interface{}) { c["aud"] = []string{"other-client"} },
+		"policy":       func(c map[string]interface{}) { c["tfp"] = "B2C_1_other" },
+		"nonce":        func(c map[string]interface{}) { c["nonce"] = "replayed" },
+		"missing exp":  func(c map[string]interface{}) { delete(c, "exp") },
+		"acr fallback": func(c map[string]interface{}) { delete(c, "tfp"); c["acr"] = "b2c_1_other" },
+	}
+
+	for name, modify := range invalid {
+		claims := ti.claims()
+		modify(claims)
+
+		if _, err := v.ValidateWithNonce(ti.token(t, claims), "nonce"); err == nil {
+			t.Errorf("Expected %s token to be rejected", name)
+		}
+	}
+
+	// swapping the payload of a valid token invalidates the signature
+	adminClaims := ti.claims()
+	adminClaims["oid"] = "admin"
+
+	parts := strings.Split(ti.token(t, ti.claims()), ".")
+	adminParts := strings.Split(ti.token(t, adminClaims), ".")
+
+	if _, err := v.Validate(parts[0] + "." + adminParts[1] + "." + parts[2]); err == nil {
+		t.Errorf("Expected token with modified payload to be rejected")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	ti := newTestIssuer(t)
+	defer ti.server.Close()
+
+	v, err := New(Config{
+		Audiences:   []string{"client-id"},
+		MetadataURL: ti.server.URL + "/.well-known/openid-configuration",
+	})
+	if err != nil {
+		t.Fatalf("Error creating validator: %s", err)
+	}
+
+	if _, err := v.Validate(ti.token(t, ti.claims())); err != nil {
+		t.Fatalf("Error validating token: %s", err)
+	}
+
+	// rotate the key, a token signed with the new key is accepted once the keys are fetched again
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ti.key, ti.keyID = newKey, "key2"
+
+	if _, err := v.Validate(ti.token(t, ti.claims())); err == nil {
+		t.Errorf("Expected unknown key to be rejected within the minimum refresh interval")
+	}
+
+	v.fetched = time.Now().Add(-minRefreshInterval - time.Second)
+
+	if _, err := v.Validate(ti.token(t, ti.claims())); err != nil {
+		t.Errorf("Error validating token signed with rotated key: %s", err)
+	}
+}
+
+func TestRefreshBackoff(t *testing.T) {
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ti := newTestIssuer(t)
+	defer ti.server.Close()
+
+	v, err := New(Config{
+		Audiences:   []string{"client-id"},
+		MetadataURL: server.URL + "/.well-known/openid-configuration",
+	})
+	if err != nil {
+		t.Fatalf("Error creating validator: %s", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := v.Validate(ti.token(t, ti.claims())); err == nil {
+			t.Errorf("Expected error while the metadata is unavailable")
+		}
+	}
+
+	if fetches != 1 {
+		t.Errorf("Expected a single fetch within the backoff, got %d", fetches)
+	}
+
+	// once the backoff has passed, the keys are fetched again
+	v.config.MetadataURL = ti.server.URL + "/.well-known/openid-configuration"
+	v.failed = time.Now().Add(-failedRefreshBackoff - time.Second)
+
+	if _, err := v.Validate(ti.token(t, ti.claims())); err != nil {
+		t.Errorf("Error validating token after the backoff: %s", err)
+	}
+}
+
+func TestNewWithoutAudiences(t *testing.T) {
+	if _, err := New(Config{TenantDomain: "example.onmicrosoft.com", Policy: "B2C_1_signupsignin"}); err == nil {
+		t.Errorf("Expected error for a config without audiences")
+	}
+}